@@ -1,6 +1,7 @@
 package hago
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -20,15 +21,15 @@ func NewAPI(client *Client) *API {
 }
 
 // GetStates returns the states of all entities
-func (a *API) GetStates() ([]State, error) {
-	resp, err := a.client.Get("/api/states")
+func (a *API) GetStates(ctx context.Context) ([]State, error) {
+	resp, err := a.client.Get(ctx, "/api/states")
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get states: %s", resp.Status)
+	if err := checkResponse(resp, http.StatusOK); err != nil {
+		return nil, fmt.Errorf("failed to get states: %w", err)
 	}
 
 	var states []State
@@ -40,15 +41,15 @@ func (a *API) GetStates() ([]State, error) {
 }
 
 // GetState returns the state of a specific entity
-func (a *API) GetState(entityID string) (*State, error) {
-	resp, err := a.client.Get(fmt.Sprintf("/api/states/%s", entityID))
+func (a *API) GetState(ctx context.Context, entityID string) (*State, error) {
+	resp, err := a.client.Get(ctx, fmt.Sprintf("/api/states/%s", entityID))
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get state for %s: %s", entityID, resp.Status)
+	if err := checkResponse(resp, http.StatusOK); err != nil {
+		return nil, fmt.Errorf("failed to get state for %s: %w", entityID, err)
 	}
 
 	var state State
@@ -60,20 +61,21 @@ func (a *API) GetState(entityID string) (*State, error) {
 }
 
 // SetState updates the state of an entity
-func (a *API) SetState(entityID, state string, attributes map[string]interface{}) (*State, error) {
+func (a *API) SetState(ctx context.Context, entityID, state string, attributes map[string]interface{}) (*State, error) {
 	body := map[string]interface{}{
 		"state":      state,
 		"attributes": attributes,
 	}
 
-	resp, err := a.client.Post(fmt.Sprintf("/api/states/%s", entityID), body)
+	// Setting a state is idempotent, so it is safe to retry
+	resp, err := a.client.Post(MarkIdempotent(ctx), fmt.Sprintf("/api/states/%s", entityID), body)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		return nil, fmt.Errorf("failed to set state for %s: %s", entityID, resp.Status)
+	if err := checkResponse(resp, http.StatusOK, http.StatusCreated); err != nil {
+		return nil, fmt.Errorf("failed to set state for %s: %w", entityID, err)
 	}
 
 	var result State
@@ -84,31 +86,59 @@ func (a *API) SetState(entityID, state string, attributes map[string]interface{}
 	return &result, nil
 }
 
-// CallService calls a Home Assistant service
-func (a *API) CallService(domain, service string, data map[string]interface{}) error {
-	resp, err := a.client.Post(fmt.Sprintf("/api/services/%s/%s", domain, service), data)
+// CallService calls a Home Assistant service on target and returns the states
+// that changed while the service was running. Pass an empty Target for
+// services that take no target or when the target is part of data.
+func (a *API) CallService(ctx context.Context, domain, service string, target Target, data map[string]interface{}) ([]State, error) {
+	resp, err := a.client.Post(ctx, fmt.Sprintf("/api/services/%s/%s", domain, service), serviceBody(target, data))
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		return fmt.Errorf("failed to call service %s.%s: %s", domain, service, resp.Status)
+	if err := checkResponse(resp, http.StatusOK, http.StatusCreated); err != nil {
+		return nil, fmt.Errorf("failed to call service %s.%s: %w", domain, service, err)
 	}
 
-	return nil
+	var changed []State
+	if err := json.NewDecoder(resp.Body).Decode(&changed); err != nil {
+		return nil, err
+	}
+
+	return changed, nil
+}
+
+// CallServiceWithResponse calls a service that returns response data, such as
+// weather.get_forecasts or calendar.get_events
+func (a *API) CallServiceWithResponse(ctx context.Context, domain, service string, target Target, data map[string]interface{}) (*ServiceCallResult, error) {
+	resp, err := a.client.Post(ctx, fmt.Sprintf("/api/services/%s/%s?return_response", domain, service), serviceBody(target, data))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp, http.StatusOK, http.StatusCreated); err != nil {
+		return nil, fmt.Errorf("failed to call service %s.%s: %w", domain, service, err)
+	}
+
+	var result ServiceCallResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
 }
 
 // GetConfig returns the current configuration of Home Assistant
-func (a *API) GetConfig() (map[string]interface{}, error) {
-	resp, err := a.client.Get("/api/config")
+func (a *API) GetConfig(ctx context.Context) (map[string]interface{}, error) {
+	resp, err := a.client.Get(ctx, "/api/config")
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get config: %s", resp.Status)
+	if err := checkResponse(resp, http.StatusOK); err != nil {
+		return nil, fmt.Errorf("failed to get config: %w", err)
 	}
 
 	var config map[string]interface{}
@@ -120,15 +150,15 @@ func (a *API) GetConfig() (map[string]interface{}, error) {
 }
 
 // GetServices returns all available services
-func (a *API) GetServices() (map[string]map[string]interface{}, error) {
-	resp, err := a.client.Get("/api/services")
+func (a *API) GetServices(ctx context.Context) (map[string]map[string]interface{}, error) {
+	resp, err := a.client.Get(ctx, "/api/services")
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get services: %s", resp.Status)
+	if err := checkResponse(resp, http.StatusOK); err != nil {
+		return nil, fmt.Errorf("failed to get services: %w", err)
 	}
 
 	// 先尝试解析为数组格式（新版Home Assistant API）
@@ -172,15 +202,15 @@ func (a *API) GetServices() (map[string]map[string]interface{}, error) {
 }
 
 // GetEvents returns all available event types
-func (a *API) GetEvents() ([]string, error) {
-	resp, err := a.client.Get("/api/events")
+func (a *API) GetEvents(ctx context.Context) ([]string, error) {
+	resp, err := a.client.Get(ctx, "/api/events")
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get events: %s", resp.Status)
+	if err := checkResponse(resp, http.StatusOK); err != nil {
+		return nil, fmt.Errorf("failed to get events: %w", err)
 	}
 
 	var events []string
@@ -192,30 +222,30 @@ func (a *API) GetEvents() ([]string, error) {
 }
 
 // FireEvent fires an event with the given event_type and data
-func (a *API) FireEvent(eventType string, data map[string]interface{}) error {
-	resp, err := a.client.Post(fmt.Sprintf("/api/events/%s", eventType), data)
+func (a *API) FireEvent(ctx context.Context, eventType string, data map[string]interface{}) error {
+	resp, err := a.client.Post(ctx, fmt.Sprintf("/api/events/%s", eventType), data)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to fire event %s: %s", eventType, resp.Status)
+	if err := checkResponse(resp, http.StatusOK); err != nil {
+		return fmt.Errorf("failed to fire event %s: %w", eventType, err)
 	}
 
 	return nil
 }
 
 // GetErrorLog returns the Home Assistant error log
-func (a *API) GetErrorLog() (string, error) {
-	resp, err := a.client.Get("/api/error_log")
+func (a *API) GetErrorLog(ctx context.Context) (string, error) {
+	resp, err := a.client.Get(ctx, "/api/error_log")
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("failed to get error log: %s", resp.Status)
+	if err := checkResponse(resp, http.StatusOK); err != nil {
+		return "", fmt.Errorf("failed to get error log: %w", err)
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -227,15 +257,15 @@ func (a *API) GetErrorLog() (string, error) {
 }
 
 // CheckAPI tests if the API is up and running
-func (a *API) CheckAPI() error {
-	resp, err := a.client.Get("/api/")
+func (a *API) CheckAPI(ctx context.Context) error {
+	resp, err := a.client.Get(ctx, "/api/")
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("API check failed: %s", resp.Status)
+	if err := checkResponse(resp, http.StatusOK); err != nil {
+		return fmt.Errorf("API check failed: %w", err)
 	}
 
 	return nil
@@ -2,6 +2,7 @@ package hago
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -15,26 +16,34 @@ type Client struct {
 	BaseURL    *url.URL
 	APIToken   string
 	HTTPClient *http.Client
+	// Header holds extra headers sent with every request
+	Header http.Header
+	// RetryPolicy controls retries of failed requests; nil disables retries
+	RetryPolicy *RetryPolicy
 }
 
 // NewClient creates a new Home Assistant client
-func NewClient(baseURL, apiToken string) (*Client, error) {
+func NewClient(baseURL, apiToken string, opts ...Option) (*Client, error) {
 	parsedURL, err := url.Parse(baseURL)
 	if err != nil {
 		return nil, fmt.Errorf("invalid URL: %v", err)
 	}
 
+	o := newOptions(opts)
+
 	return &Client{
-		BaseURL:  parsedURL,
-		APIToken: apiToken,
-		HTTPClient: &http.Client{
-			Timeout: time.Second * 30,
-		},
+		BaseURL:     parsedURL,
+		APIToken:    apiToken,
+		HTTPClient:  o.buildHTTPClient(),
+		Header:      o.header,
+		RetryPolicy: o.retryPolicy,
 	}, nil
 }
 
-// doRequest performs an HTTP request with the proper authentication
-func (c *Client) doRequest(method, path string, body interface{}) (*http.Response, error) {
+// doRequest performs an HTTP request with the proper authentication.
+// The request is bound to ctx, so cancelling ctx aborts it. Failed attempts
+// are retried according to the client's RetryPolicy.
+func (c *Client) doRequest(ctx context.Context, method, path string, body interface{}) (*http.Response, error) {
 	endpoint, err := url.Parse(path)
 	if err != nil {
 		return nil, err
@@ -42,42 +51,83 @@ func (c *Client) doRequest(method, path string, body interface{}) (*http.Respons
 
 	requestURL := c.BaseURL.ResolveReference(endpoint)
 
-	var bodyReader io.Reader
+	var bodyBytes []byte
 	if body != nil {
-		bodyBytes, err := json.Marshal(body)
+		bodyBytes, err = json.Marshal(body)
 		if err != nil {
 			return nil, fmt.Errorf("failed to marshal body: %v", err)
 		}
-		bodyReader = bytes.NewReader(bodyBytes)
 	}
 
-	req, err := http.NewRequest(method, requestURL.String(), bodyReader)
-	if err != nil {
-		return nil, err
-	}
+	policy := c.RetryPolicy
+	attempts := policy.attempts(ctx, method)
 
-	req.Header.Set("Authorization", "Bearer "+c.APIToken)
-	req.Header.Set("Content-Type", "application/json")
+	for attempt := 1; ; attempt++ {
+		var bodyReader io.Reader
+		if bodyBytes != nil {
+			bodyReader = bytes.NewReader(bodyBytes)
+		}
 
-	return c.HTTPClient.Do(req)
+		req, err := http.NewRequestWithContext(ctx, method, requestURL.String(), bodyReader)
+		if err != nil {
+			return nil, err
+		}
+
+		for key, values := range c.Header {
+			for _, value := range values {
+				req.Header.Add(key, value)
+			}
+		}
+		req.Header.Set("Authorization", "Bearer "+c.APIToken)
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := c.HTTPClient.Do(req)
+		if attempt >= attempts {
+			return resp, err
+		}
+
+		var wait time.Duration
+		if err != nil {
+			// Do not retry once the caller has given up
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			wait = policy.backoff(attempt)
+		} else if policy.retryableStatus(resp.StatusCode) {
+			var ok bool
+			if wait, ok = policy.retryWait(attempt, resp); !ok {
+				// The server wants a longer pause than the policy allows
+				return resp, nil
+			}
+			// Drain the body so the connection can be reused
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		} else {
+			return resp, nil
+		}
+
+		if err := sleepContext(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
 }
 
 // Get sends a GET request to the Home Assistant API
-func (c *Client) Get(path string) (*http.Response, error) {
-	return c.doRequest(http.MethodGet, path, nil)
+func (c *Client) Get(ctx context.Context, path string) (*http.Response, error) {
+	return c.doRequest(ctx, http.MethodGet, path, nil)
 }
 
 // Post sends a POST request to the Home Assistant API
-func (c *Client) Post(path string, body interface{}) (*http.Response, error) {
-	return c.doRequest(http.MethodPost, path, body)
+func (c *Client) Post(ctx context.Context, path string, body interface{}) (*http.Response, error) {
+	return c.doRequest(ctx, http.MethodPost, path, body)
 }
 
 // Put sends a PUT request to the Home Assistant API
-func (c *Client) Put(path string, body interface{}) (*http.Response, error) {
-	return c.doRequest(http.MethodPut, path, body)
+func (c *Client) Put(ctx context.Context, path string, body interface{}) (*http.Response, error) {
+	return c.doRequest(ctx, http.MethodPut, path, body)
 }
 
 // Delete sends a DELETE request to the Home Assistant API
-func (c *Client) Delete(path string) (*http.Response, error) {
-	return c.doRequest(http.MethodDelete, path, nil)
+func (c *Client) Delete(ctx context.Context, path string) (*http.Response, error) {
+	return c.doRequest(ctx, http.MethodDelete, path, nil)
 }
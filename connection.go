@@ -0,0 +1,189 @@
+package hago
+
+import (
+	"context"
+)
+
+// ConnectionState describes the lifecycle of a WSClient connection
+type ConnectionState int
+
+const (
+	// ConnDisconnected means the client is not connected and not trying to
+	// connect, either before Connect or after reconnecting was given up
+	ConnDisconnected ConnectionState = iota
+	// ConnConnecting means the client is dialing Home Assistant
+	ConnConnecting
+	// ConnAuthenticating means the connection is open and the auth handshake is running
+	ConnAuthenticating
+	// ConnConnected means the client is authenticated and ready for commands
+	ConnConnected
+	// ConnReconnecting means the connection was lost and the client is trying to restore it
+	ConnReconnecting
+	// ConnClosed means the client was closed; it cannot connect again
+	ConnClosed
+)
+
+// String returns the name of the state
+func (s ConnectionState) String() string {
+	switch s {
+	case ConnDisconnected:
+		return "disconnected"
+	case ConnConnecting:
+		return "connecting"
+	case ConnAuthenticating:
+		return "authenticating"
+	case ConnConnected:
+		return "connected"
+	case ConnReconnecting:
+		return "reconnecting"
+	case ConnClosed:
+		return "closed"
+	}
+	return "unknown"
+}
+
+// ConnectionEvent reports a connection state transition
+type ConnectionEvent struct {
+	State    ConnectionState
+	Previous ConnectionState
+	// Err is the cause of the transition, e.g. a read, dial or authentication error
+	Err error
+	// Attempt is the reconnect attempt number while reconnecting
+	Attempt int
+}
+
+// ConnectionState returns the current state of the connection
+func (c *WSClient) ConnectionState() ConnectionState {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	return c.state
+}
+
+// HAVersion returns the Home Assistant version reported during the last
+// successful handshake, or an empty string before the first connect
+func (c *WSClient) HAVersion() string {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	return c.haVersion
+}
+
+// OnConnectionStateChange registers a handler called for every state transition.
+// Handlers are called in order on a separate goroutine, so they may call back
+// into the client, but a slow handler delays the events that follow.
+func (c *WSClient) OnConnectionStateChange(handler func(ConnectionEvent)) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	c.stateHandlers = append(c.stateHandlers, handler)
+}
+
+// WaitConnected blocks until the client is connected, the client is closed
+// or ctx is done
+func (c *WSClient) WaitConnected(ctx context.Context) error {
+	for {
+		c.stateMu.Lock()
+		state := c.state
+		changed := c.stateChanged
+		c.stateMu.Unlock()
+
+		switch state {
+		case ConnConnected:
+			return nil
+		case ConnClosed:
+			return ErrClientClosed
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// waitReconnect blocks until the reconnect in progress succeeds, gives up,
+// the client is closed or ctx is done. Giving up returns the reason.
+func (c *WSClient) waitReconnect(ctx context.Context) error {
+	for {
+		c.stateMu.Lock()
+		state, err, changed := c.state, c.stateErr, c.stateChanged
+		c.stateMu.Unlock()
+
+		// The state still reads connected until the reconnect announces itself
+		c.mu.Lock()
+		connected := c.connected
+		c.mu.Unlock()
+
+		switch {
+		case state == ConnConnected && connected:
+			return nil
+		case state == ConnClosed:
+			return ErrClientClosed
+		case state == ConnDisconnected:
+			if err == nil {
+				err = ErrNotConnected
+			}
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// setState records a state transition and notifies waiters and handlers
+func (c *WSClient) setState(event ConnectionEvent) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	// A closed client stays closed, even if a dial it aborted reports late
+	if c.state == ConnClosed {
+		return
+	}
+
+	event.Previous = c.state
+	if event.State == event.Previous && event.Err == nil && event.Attempt == 0 {
+		return
+	}
+	c.state = event.State
+	c.stateErr = event.Err
+
+	// Wake up WaitConnected callers
+	close(c.stateChanged)
+	c.stateChanged = make(chan struct{})
+
+	if len(c.stateHandlers) == 0 {
+		return
+	}
+
+	c.stateQueue = append(c.stateQueue, event)
+	if !c.stateDraining {
+		c.stateDraining = true
+		go c.drainStateEvents()
+	}
+}
+
+// drainStateEvents delivers queued state events in order until the queue is empty
+func (c *WSClient) drainStateEvents() {
+	for {
+		c.stateMu.Lock()
+		if len(c.stateQueue) == 0 {
+			c.stateDraining = false
+			c.stateMu.Unlock()
+			return
+		}
+		event := c.stateQueue[0]
+		c.stateQueue = c.stateQueue[1:]
+		handlers := append([]func(ConnectionEvent){}, c.stateHandlers...)
+		c.stateMu.Unlock()
+
+		for _, handler := range handlers {
+			handler(event)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package hago
+
+import (
+	"hash/fnv"
+	"sync/atomic"
+)
+
+// DispatchMode controls how messages are handed to handlers registered with
+// AddEventHandler
+type DispatchMode int
+
+const (
+	// DispatchOrdered runs all handlers on a single goroutine in the order the
+	// messages arrived
+	DispatchOrdered DispatchMode = iota
+	// DispatchPerHandler gives every registered handler its own queue, so each
+	// one sees messages in order and a slow handler only delays itself
+	DispatchPerHandler
+	// DispatchPerEntity serializes messages per entity_id and runs different
+	// entities concurrently on a fixed number of workers
+	DispatchPerEntity
+)
+
+// OverflowPolicy decides what happens to a message when its queue is full
+type OverflowPolicy int
+
+const (
+	// OverflowDropOldest discards the oldest queued message to make room.
+	// It is the zero value, so a partially filled DispatchPolicy never blocks.
+	OverflowDropOldest OverflowPolicy = iota
+	// OverflowDropNewest discards the message that did not fit
+	OverflowDropNewest
+	// OverflowBlock waits for room in the queue. Once a queue is full the reader
+	// stalls: results, pongs and events for everything else go unread until the
+	// consumer catches up, so a consumer must not wait on the client itself.
+	OverflowBlock
+)
+
+// DispatchPolicy configures the delivery of WebSocket messages to handlers,
+// subscription channels and subscription callbacks
+type DispatchPolicy struct {
+	// Mode applies to handlers registered with AddEventHandler; subscriptions
+	// are always delivered in order, each on its own queue
+	Mode DispatchMode
+	// QueueSize is the capacity of every queue and subscription channel
+	QueueSize int
+	// Overflow applies to all queues and subscription channels
+	Overflow OverflowPolicy
+	// Workers is the number of goroutines used by DispatchPerEntity
+	Workers int
+}
+
+// DefaultDispatchPolicy returns the policy used when WithDispatchPolicy is not
+// given: ordered delivery with queues of 256 messages that drop the oldest
+// message when full, so a slow consumer never stalls the connection
+func DefaultDispatchPolicy() DispatchPolicy {
+	return DispatchPolicy{
+		Mode:      DispatchOrdered,
+		QueueSize: 256,
+		Overflow:  OverflowDropOldest,
+		Workers:   8,
+	}
+}
+
+// DispatchStats counts the messages handed to handlers and subscriptions
+type DispatchStats struct {
+	// Queued is the number of messages queued for a handler or subscription
+	Queued uint64
+	// Dropped is the number of messages discarded by the overflow policy,
+	// including queued messages evicted by OverflowDropOldest
+	Dropped uint64
+}
+
+// eventHandler is a handler registered with AddEventHandler
+type eventHandler struct {
+	fn func(msg map[string]interface{})
+	// queue is the handler's own queue in DispatchPerHandler mode
+	queue *dispatchQueue
+}
+
+// dispatchQueue is a bounded queue drained in order by a single goroutine
+type dispatchQueue struct {
+	items  chan func()
+	client *WSClient
+	// done stops the worker
+	done <-chan struct{}
+	// dropped, if set, also counts the messages dropped by this queue
+	dropped *atomic.Uint64
+}
+
+// newDispatchQueue creates a queue and starts its worker, which runs until
+// done is closed
+func (c *WSClient) newDispatchQueue(done <-chan struct{}, dropped *atomic.Uint64) *dispatchQueue {
+	q := &dispatchQueue{
+		items:   make(chan func(), c.dispatchPolicy.QueueSize),
+		client:  c,
+		done:    done,
+		dropped: dropped,
+	}
+	go q.run()
+	return q
+}
+
+// run executes queued items until the queue is stopped
+func (q *dispatchQueue) run() {
+	for {
+		select {
+		case item := <-q.items:
+			item()
+		case <-q.done:
+			return
+		}
+	}
+}
+
+// push queues an item following the overflow policy
+func (q *dispatchQueue) push(item func()) {
+	queued, dropped := offer(q.items, item, q.client.dispatchPolicy.Overflow, q.done)
+	q.client.countDelivery(queued, dropped)
+	if q.dropped != nil {
+		q.dropped.Add(uint64(dropped))
+	}
+}
+
+// offer puts v into ch following the overflow policy. It reports whether v was
+// queued and how many messages, v or older ones, were discarded because ch was
+// full. A blocking send aborted by done neither queues nor drops.
+func offer[T any](ch chan T, v T, overflow OverflowPolicy, done <-chan struct{}) (bool, int) {
+	switch overflow {
+	case OverflowDropNewest:
+		select {
+		case ch <- v:
+			return true, 0
+		default:
+			return false, 1
+		}
+	case OverflowDropOldest:
+		dropped := 0
+		for {
+			select {
+			case ch <- v:
+				return true, dropped
+			default:
+			}
+			select {
+			case <-ch:
+				dropped++
+			default:
+			}
+		}
+	default:
+		select {
+		case ch <- v:
+			return true, 0
+		case <-done:
+			return false, 0
+		}
+	}
+}
+
+// countDelivery updates the dispatch statistics
+func (c *WSClient) countDelivery(queued bool, dropped int) {
+	if queued {
+		c.queued.Add(1)
+	}
+	c.dropped.Add(uint64(dropped))
+}
+
+// dispatch hands a decoded message to the handlers registered for its type
+func (c *WSClient) dispatch(handlers []*eventHandler, msg map[string]interface{}) {
+	switch c.dispatchPolicy.Mode {
+	case DispatchPerHandler:
+		for _, handler := range handlers {
+			handler.queue.push(func() {
+				handler.fn(msg)
+			})
+		}
+		return
+	case DispatchPerEntity:
+		c.entityQueue(entityIDOf(msg)).push(func() {
+			for _, handler := range handlers {
+				handler.fn(msg)
+			}
+		})
+		return
+	}
+
+	c.orderedQueue().push(func() {
+		for _, handler := range handlers {
+			handler.fn(msg)
+		}
+	})
+}
+
+// orderedQueue returns the queue shared by all handlers, creating it on first use
+func (c *WSClient) orderedQueue() *dispatchQueue {
+	return c.entityQueue("")
+}
+
+// entityQueue returns the queue that serializes messages of the given entity,
+// creating the worker queues on first use
+func (c *WSClient) entityQueue(entityID string) *dispatchQueue {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.queues == nil {
+		workers := 1
+		if c.dispatchPolicy.Mode == DispatchPerEntity {
+			workers = c.dispatchPolicy.Workers
+		}
+		c.queues = make([]*dispatchQueue, workers)
+		for i := range c.queues {
+			c.queues[i] = c.newDispatchQueue(c.done, nil)
+		}
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(entityID))
+	return c.queues[h.Sum32()%uint32(len(c.queues))]
+}
+
+// entityIDOf returns the entity_id of an event message, or an empty string
+func entityIDOf(msg map[string]interface{}) string {
+	event, _ := msg["event"].(map[string]interface{})
+	data, _ := event["data"].(map[string]interface{})
+	entityID, _ := data["entity_id"].(string)
+	return entityID
+}
+
+// DispatchStats returns how many messages were queued for handlers and
+// subscriptions and how many were dropped because a queue was full
+func (c *WSClient) DispatchStats() DispatchStats {
+	return DispatchStats{
+		Queued:  c.queued.Load(),
+		Dropped: c.dropped.Load(),
+	}
+}
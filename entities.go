@@ -1,6 +1,7 @@
 package hago
 
 import (
+	"context"
 	"fmt"
 	"strings"
 )
@@ -17,71 +18,47 @@ func NewEntities(api *API) *Entities {
 	}
 }
 
-// LightTurnOn turns on a light entity
-func (e *Entities) LightTurnOn(entityID string, options map[string]interface{}) error {
-	if !strings.HasPrefix(entityID, "light.") {
-		entityID = "light." + entityID
+// callService calls a service on target, discarding the changed states.
+// Entity IDs of the target may omit the domain.
+func (e *Entities) callService(ctx context.Context, domain, service string, target Target, data map[string]interface{}) error {
+	if target.IsEmpty() {
+		return fmt.Errorf("%w: %s.%s needs a target", ErrInvalidArgument, domain, service)
 	}
 
-	data := map[string]interface{}{
-		"entity_id": entityID,
-	}
+	_, err := e.api.CallService(ctx, domain, service, target.withDomain(domain), data)
+	return err
+}
+
+// LightTurnOn turns on the lights selected by target
+func (e *Entities) LightTurnOn(ctx context.Context, target Target, options map[string]interface{}) error {
+	data := map[string]interface{}{}
 
 	// Merge options into data
 	for k, v := range options {
 		data[k] = v
 	}
 
-	return e.api.CallService("light", "turn_on", data)
+	return e.callService(ctx, "light", "turn_on", target, data)
 }
 
-// LightTurnOff turns off a light entity
-func (e *Entities) LightTurnOff(entityID string) error {
-	if !strings.HasPrefix(entityID, "light.") {
-		entityID = "light." + entityID
-	}
-
-	data := map[string]interface{}{
-		"entity_id": entityID,
-	}
-
-	return e.api.CallService("light", "turn_off", data)
+// LightTurnOff turns off the lights selected by target
+func (e *Entities) LightTurnOff(ctx context.Context, target Target) error {
+	return e.callService(ctx, "light", "turn_off", target, nil)
 }
 
-// SwitchTurnOn turns on a switch entity
-func (e *Entities) SwitchTurnOn(entityID string) error {
-	if !strings.HasPrefix(entityID, "switch.") {
-		entityID = "switch." + entityID
-	}
-
-	data := map[string]interface{}{
-		"entity_id": entityID,
-	}
-
-	return e.api.CallService("switch", "turn_on", data)
+// SwitchTurnOn turns on the switches selected by target
+func (e *Entities) SwitchTurnOn(ctx context.Context, target Target) error {
+	return e.callService(ctx, "switch", "turn_on", target, nil)
 }
 
-// SwitchTurnOff turns off a switch entity
-func (e *Entities) SwitchTurnOff(entityID string) error {
-	if !strings.HasPrefix(entityID, "switch.") {
-		entityID = "switch." + entityID
-	}
-
-	data := map[string]interface{}{
-		"entity_id": entityID,
-	}
-
-	return e.api.CallService("switch", "turn_off", data)
+// SwitchTurnOff turns off the switches selected by target
+func (e *Entities) SwitchTurnOff(ctx context.Context, target Target) error {
+	return e.callService(ctx, "switch", "turn_off", target, nil)
 }
 
-// ClimateSetTemperature sets the temperature for a climate entity
-func (e *Entities) ClimateSetTemperature(entityID string, temperature float64, options map[string]interface{}) error {
-	if !strings.HasPrefix(entityID, "climate.") {
-		entityID = "climate." + entityID
-	}
-
+// ClimateSetTemperature sets the temperature of the climate entities selected by target
+func (e *Entities) ClimateSetTemperature(ctx context.Context, target Target, temperature float64, options map[string]interface{}) error {
 	data := map[string]interface{}{
-		"entity_id":   entityID,
 		"temperature": temperature,
 	}
 
@@ -90,108 +67,58 @@ func (e *Entities) ClimateSetTemperature(entityID string, temperature float64, o
 		data[k] = v
 	}
 
-	return e.api.CallService("climate", "set_temperature", data)
+	return e.callService(ctx, "climate", "set_temperature", target, data)
 }
 
-// ClimateSetHVACMode sets the HVAC mode for a climate entity
-func (e *Entities) ClimateSetHVACMode(entityID string, hvacMode string) error {
-	if !strings.HasPrefix(entityID, "climate.") {
-		entityID = "climate." + entityID
-	}
-
+// ClimateSetHVACMode sets the HVAC mode of the climate entities selected by target
+func (e *Entities) ClimateSetHVACMode(ctx context.Context, target Target, hvacMode string) error {
 	data := map[string]interface{}{
-		"entity_id": entityID,
 		"hvac_mode": hvacMode,
 	}
 
-	return e.api.CallService("climate", "set_hvac_mode", data)
+	return e.callService(ctx, "climate", "set_hvac_mode", target, data)
 }
 
-// CoverOpen opens a cover entity
-func (e *Entities) CoverOpen(entityID string) error {
-	if !strings.HasPrefix(entityID, "cover.") {
-		entityID = "cover." + entityID
-	}
-
-	data := map[string]interface{}{
-		"entity_id": entityID,
-	}
-
-	return e.api.CallService("cover", "open_cover", data)
+// CoverOpen opens the covers selected by target
+func (e *Entities) CoverOpen(ctx context.Context, target Target) error {
+	return e.callService(ctx, "cover", "open_cover", target, nil)
 }
 
-// CoverClose closes a cover entity
-func (e *Entities) CoverClose(entityID string) error {
-	if !strings.HasPrefix(entityID, "cover.") {
-		entityID = "cover." + entityID
-	}
-
-	data := map[string]interface{}{
-		"entity_id": entityID,
-	}
-
-	return e.api.CallService("cover", "close_cover", data)
+// CoverClose closes the covers selected by target
+func (e *Entities) CoverClose(ctx context.Context, target Target) error {
+	return e.callService(ctx, "cover", "close_cover", target, nil)
 }
 
-// CoverSetPosition sets the position of a cover entity
-func (e *Entities) CoverSetPosition(entityID string, position int) error {
-	if !strings.HasPrefix(entityID, "cover.") {
-		entityID = "cover." + entityID
-	}
-
+// CoverSetPosition sets the position of the covers selected by target
+func (e *Entities) CoverSetPosition(ctx context.Context, target Target, position int) error {
 	if position < 0 || position > 100 {
-		return fmt.Errorf("position must be between 0 and 100")
+		return fmt.Errorf("%w: position must be between 0 and 100", ErrInvalidArgument)
 	}
 
 	data := map[string]interface{}{
-		"entity_id": entityID,
-		"position":  position,
+		"position": position,
 	}
 
-	return e.api.CallService("cover", "set_cover_position", data)
+	return e.callService(ctx, "cover", "set_cover_position", target, data)
 }
 
-// MediaPlay plays media on a media player entity
-func (e *Entities) MediaPlay(entityID string) error {
-	if !strings.HasPrefix(entityID, "media_player.") {
-		entityID = "media_player." + entityID
-	}
-
-	data := map[string]interface{}{
-		"entity_id": entityID,
-	}
-
-	return e.api.CallService("media_player", "media_play", data)
+// MediaPlay plays media on the media players selected by target
+func (e *Entities) MediaPlay(ctx context.Context, target Target) error {
+	return e.callService(ctx, "media_player", "media_play", target, nil)
 }
 
-// MediaPause pauses media on a media player entity
-func (e *Entities) MediaPause(entityID string) error {
-	if !strings.HasPrefix(entityID, "media_player.") {
-		entityID = "media_player." + entityID
-	}
-
-	data := map[string]interface{}{
-		"entity_id": entityID,
-	}
-
-	return e.api.CallService("media_player", "media_pause", data)
+// MediaPause pauses media on the media players selected by target
+func (e *Entities) MediaPause(ctx context.Context, target Target) error {
+	return e.callService(ctx, "media_player", "media_pause", target, nil)
 }
 
-// MediaStop stops media on a media player entity
-func (e *Entities) MediaStop(entityID string) error {
-	if !strings.HasPrefix(entityID, "media_player.") {
-		entityID = "media_player." + entityID
-	}
-
-	data := map[string]interface{}{
-		"entity_id": entityID,
-	}
-
-	return e.api.CallService("media_player", "media_stop", data)
+// MediaStop stops media on the media players selected by target
+func (e *Entities) MediaStop(ctx context.Context, target Target) error {
+	return e.callService(ctx, "media_player", "media_stop", target, nil)
 }
 
 // ScriptRun runs a script entity
-func (e *Entities) ScriptRun(entityID string, variables map[string]interface{}) error {
+func (e *Entities) ScriptRun(ctx context.Context, entityID string, variables map[string]interface{}) error {
 	// Remove "script." prefix if it exists
 	scriptName := entityID
 	if strings.HasPrefix(entityID, "script.") {
@@ -205,49 +132,35 @@ func (e *Entities) ScriptRun(entityID string, variables map[string]interface{})
 		data["variables"] = variables
 	}
 
-	return e.api.CallService("script", scriptName, data)
+	// Scripts are services of their own and take no target
+	_, err := e.api.CallService(ctx, "script", scriptName, Target{}, data)
+	return err
 }
 
-// SceneTurnOn activates a scene
-func (e *Entities) SceneTurnOn(entityID string) error {
-	if !strings.HasPrefix(entityID, "scene.") {
-		entityID = "scene." + entityID
-	}
-
-	data := map[string]interface{}{
-		"entity_id": entityID,
-	}
-
-	return e.api.CallService("scene", "turn_on", data)
+// SceneTurnOn activates the scenes selected by target
+func (e *Entities) SceneTurnOn(ctx context.Context, target Target) error {
+	return e.callService(ctx, "scene", "turn_on", target, nil)
 }
 
-// AutomationTrigger triggers an automation
-func (e *Entities) AutomationTrigger(entityID string) error {
-	if !strings.HasPrefix(entityID, "automation.") {
-		entityID = "automation." + entityID
-	}
-
-	data := map[string]interface{}{
-		"entity_id": entityID,
-	}
-
-	return e.api.CallService("automation", "trigger", data)
+// AutomationTrigger triggers the automations selected by target
+func (e *Entities) AutomationTrigger(ctx context.Context, target Target) error {
+	return e.callService(ctx, "automation", "trigger", target, nil)
 }
 
 // GetSensor gets the state of a sensor
-func (e *Entities) GetSensor(entityID string) (*State, error) {
+func (e *Entities) GetSensor(ctx context.Context, entityID string) (*State, error) {
 	if !strings.HasPrefix(entityID, "sensor.") {
 		entityID = "sensor." + entityID
 	}
 
-	return e.api.GetState(entityID)
+	return e.api.GetState(ctx, entityID)
 }
 
 // GetBinarySensor gets the state of a binary sensor
-func (e *Entities) GetBinarySensor(entityID string) (*State, error) {
+func (e *Entities) GetBinarySensor(ctx context.Context, entityID string) (*State, error) {
 	if !strings.HasPrefix(entityID, "binary_sensor.") {
 		entityID = "binary_sensor." + entityID
 	}
 
-	return e.api.GetState(entityID)
+	return e.api.GetState(ctx, entityID)
 }
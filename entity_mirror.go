@@ -0,0 +1,338 @@
+package hago
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// EntityChange describes a change applied to an EntityMirror
+type EntityChange struct {
+	EntityID string
+	// Old is the previous state, nil when the entity was added
+	Old *State
+	// New is the current state, nil when the entity was removed
+	New *State
+}
+
+// EntityMirror is an in-memory copy of entity states kept up to date by a
+// subscribe_entities subscription. It is safe for concurrent use.
+type EntityMirror struct {
+	*Subscription
+
+	mu       sync.RWMutex
+	states   map[string]State
+	handlers []func(EntityChange)
+	ready    chan struct{}
+	// resync makes the next full snapshot replace the mirror, e.g. after a reconnect
+	resync bool
+}
+
+// compressedState is an entity state in the compressed subscribe_entities format.
+// Fields are pointers because diffs only carry what changed.
+type compressedState struct {
+	State       *string                `json:"s"`
+	Attributes  map[string]interface{} `json:"a"`
+	Context     json.RawMessage        `json:"c"`
+	LastChanged *float64               `json:"lc"`
+	LastUpdated *float64               `json:"lu"`
+}
+
+// entityDiff is a change to an entity: "+" carries new or changed values,
+// "-" the attributes that were removed
+type entityDiff struct {
+	Additions *compressedState `json:"+"`
+	Removals  *struct {
+		Attributes []string `json:"a"`
+	} `json:"-,"` // "-," because a plain "-" tag skips the field
+}
+
+// entitiesEvent is an event of a subscribe_entities subscription
+type entitiesEvent struct {
+	Added   map[string]compressedState `json:"a"`
+	Changed map[string]entityDiff      `json:"c"`
+	Removed []string                   `json:"r"`
+}
+
+// SubscribeEntities subscribes to state changes of the given entities, or of all
+// entities when none are given, and mirrors their states locally. Home Assistant
+// streams compact diffs instead of full state_changed events, which is much
+// lighter on instances with many entities.
+func (c *WSClient) SubscribeEntities(ctx context.Context, entityIDs ...string) (*EntityMirror, error) {
+	message := map[string]interface{}{
+		"type": "subscribe_entities",
+	}
+	if len(entityIDs) > 0 {
+		message["entity_ids"] = entityIDs
+	}
+
+	mirror := &EntityMirror{
+		states: make(map[string]State),
+		ready:  make(chan struct{}),
+	}
+	mirror.Subscription = c.newSubscription(message, func(msg *wsMessage) {
+		var event entitiesEvent
+		if err := json.Unmarshal(msg.Event, &event); err != nil {
+			log.Printf("Error decoding entities event: %v", err)
+			return
+		}
+		mirror.apply(&event)
+	})
+	// The server sends a full snapshot again once the subscription is replayed
+	mirror.onResubscribe = func() {
+		mirror.mu.Lock()
+		mirror.resync = true
+		mirror.mu.Unlock()
+	}
+
+	if err := c.subscribe(ctx, mirror.Subscription); err != nil {
+		return nil, fmt.Errorf("failed to subscribe to entities: %w", err)
+	}
+
+	return mirror, nil
+}
+
+// Get returns the current state of an entity
+func (m *EntityMirror) Get(entityID string) (State, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	state, ok := m.states[entityID]
+	return state, ok
+}
+
+// All returns the current states of all mirrored entities, sorted by entity ID
+func (m *EntityMirror) All() []State {
+	m.mu.RLock()
+	states := make([]State, 0, len(m.states))
+	for _, state := range m.states {
+		states = append(states, state)
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].EntityID < states[j].EntityID
+	})
+	return states
+}
+
+// Len returns the number of mirrored entities
+func (m *EntityMirror) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return len(m.states)
+}
+
+// WaitReady blocks until the initial snapshot was received or ctx is done.
+// It returns ErrClientClosed when the client was closed and
+// ErrSubscriptionEnded when only the subscription ended, e.g. after
+// Unsubscribe or a failed replay.
+func (m *EntityMirror) WaitReady(ctx context.Context) error {
+	select {
+	case <-m.ready:
+		return nil
+	case <-m.done:
+		m.client.mu.Lock()
+		closed := m.client.closed
+		m.client.mu.Unlock()
+
+		if closed {
+			return ErrClientClosed
+		}
+		return ErrSubscriptionEnded
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// OnChange registers a handler called for every entity that is added, changed
+// or removed. Calls are queued per mirror following the dispatch policy; the
+// mirror itself is always updated, even when a change notification is dropped.
+func (m *EntityMirror) OnChange(handler func(EntityChange)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.handlers = append(m.handlers, handler)
+}
+
+// apply merges an event into the mirror and notifies the change handlers
+func (m *EntityMirror) apply(event *entitiesEvent) {
+	var changes []EntityChange
+
+	m.mu.Lock()
+	if event.Added != nil && m.resync {
+		// Entities missing from the new snapshot were removed while disconnected
+		m.resync = false
+		for entityID, old := range m.states {
+			if _, ok := event.Added[entityID]; !ok {
+				delete(m.states, entityID)
+				changes = append(changes, EntityChange{EntityID: entityID, Old: &old})
+			}
+		}
+	}
+
+	for entityID, compressed := range event.Added {
+		state := compressed.expand(entityID)
+		changes = append(changes, m.set(entityID, state))
+	}
+
+	for entityID, diff := range event.Changed {
+		old, ok := m.states[entityID]
+		if !ok {
+			// A diff without a base cannot be applied
+			continue
+		}
+		changes = append(changes, m.set(entityID, diff.applyTo(old)))
+	}
+
+	for _, entityID := range event.Removed {
+		if old, ok := m.states[entityID]; ok {
+			delete(m.states, entityID)
+			changes = append(changes, EntityChange{EntityID: entityID, Old: &old})
+		}
+	}
+
+	handlers := m.handlers
+	m.mu.Unlock()
+
+	// The first snapshot makes the mirror ready
+	if event.Added != nil {
+		select {
+		case <-m.ready:
+		default:
+			close(m.ready)
+		}
+	}
+
+	if len(handlers) == 0 {
+		return
+	}
+	for _, change := range changes {
+		m.enqueue(func() {
+			for _, handler := range handlers {
+				handler(change)
+			}
+		})
+	}
+}
+
+// set stores a state and returns the change it made. m.mu must be held.
+func (m *EntityMirror) set(entityID string, state State) EntityChange {
+	change := EntityChange{EntityID: entityID, New: &state}
+	if old, ok := m.states[entityID]; ok {
+		change.Old = &old
+	}
+	m.states[entityID] = state
+	return change
+}
+
+// expand converts a full compressed state into a State
+func (s *compressedState) expand(entityID string) State {
+	state := State{
+		EntityID:   entityID,
+		Attributes: s.Attributes,
+	}
+	if s.State != nil {
+		state.State = *s.State
+	}
+	if state.Attributes == nil {
+		state.Attributes = make(map[string]interface{})
+	}
+	if s.LastChanged != nil {
+		state.LastChanged = fromTimestamp(*s.LastChanged)
+		state.LastUpdated = state.LastChanged
+	}
+	// last_updated is only sent when it differs from last_changed
+	if s.LastUpdated != nil {
+		state.LastUpdated = fromTimestamp(*s.LastUpdated)
+	}
+	state.Context = decodeCompressedContext(s.Context, Context{})
+	return state
+}
+
+// applyTo returns old with the diff applied. The attributes map of old is
+// never modified, as it may still be referenced by callers of the mirror.
+func (d *entityDiff) applyTo(old State) State {
+	state := old
+
+	if d.Removals != nil && len(d.Removals.Attributes) > 0 || d.Additions != nil && len(d.Additions.Attributes) > 0 {
+		attributes := make(map[string]interface{}, len(old.Attributes))
+		for key, value := range old.Attributes {
+			attributes[key] = value
+		}
+		if d.Removals != nil {
+			for _, key := range d.Removals.Attributes {
+				delete(attributes, key)
+			}
+		}
+		if d.Additions != nil {
+			for key, value := range d.Additions.Attributes {
+				attributes[key] = value
+			}
+		}
+		state.Attributes = attributes
+	}
+
+	if add := d.Additions; add != nil {
+		if add.State != nil {
+			state.State = *add.State
+		}
+		// A new last_changed implies the same last_updated
+		if add.LastChanged != nil {
+			state.LastChanged = fromTimestamp(*add.LastChanged)
+			state.LastUpdated = state.LastChanged
+		} else if add.LastUpdated != nil {
+			state.LastUpdated = fromTimestamp(*add.LastUpdated)
+		}
+		state.Context = decodeCompressedContext(add.Context, old.Context)
+	}
+
+	return state
+}
+
+// decodeCompressedContext applies a context sent either as a bare ID or as an
+// object to base. Diffs only carry the fields that changed, so fields missing
+// from the object are kept, while null clears them.
+func decodeCompressedContext(raw json.RawMessage, base Context) Context {
+	if len(raw) == 0 || string(raw) == "null" {
+		return base
+	}
+
+	var id string
+	if err := json.Unmarshal(raw, &id); err == nil {
+		base.ID = id
+		return base
+	}
+
+	var fields map[string]*string
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return base
+	}
+	for key, value := range fields {
+		var v string
+		if value != nil {
+			v = *value
+		}
+		switch key {
+		case "id":
+			base.ID = v
+		case "parent_id":
+			base.ParentID = v
+		case "user_id":
+			base.UserID = v
+		}
+	}
+	return base
+}
+
+// fromTimestamp converts a Unix timestamp in fractional seconds to a time
+func fromTimestamp(ts float64) time.Time {
+	sec, frac := math.Modf(ts)
+	return time.Unix(int64(sec), int64(frac*1e9)).UTC()
+}
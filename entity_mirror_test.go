@@ -0,0 +1,253 @@
+package hago
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCompressedStateExpand(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want State
+	}{
+		{
+			name: "full state",
+			raw:  `{"s":"on","a":{"brightness":255},"c":"01HCTX","lc":1700000000.5}`,
+			want: State{
+				EntityID:    "light.kitchen",
+				State:       "on",
+				Attributes:  map[string]interface{}{"brightness": float64(255)},
+				LastChanged: time.Unix(1700000000, 5e8).UTC(),
+				LastUpdated: time.Unix(1700000000, 5e8).UTC(),
+				Context:     Context{ID: "01HCTX"},
+			},
+		},
+		{
+			name: "last_updated differs from last_changed",
+			raw:  `{"s":"off","a":{},"c":{"id":"01HCTX","user_id":"abc"},"lc":1700000000,"lu":1700000100}`,
+			want: State{
+				EntityID:    "light.kitchen",
+				State:       "off",
+				Attributes:  map[string]interface{}{},
+				LastChanged: time.Unix(1700000000, 0).UTC(),
+				LastUpdated: time.Unix(1700000100, 0).UTC(),
+				Context:     Context{ID: "01HCTX", UserID: "abc"},
+			},
+		},
+		{
+			name: "missing attributes",
+			raw:  `{"s":"unavailable"}`,
+			want: State{
+				EntityID:   "light.kitchen",
+				State:      "unavailable",
+				Attributes: map[string]interface{}{},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s compressedState
+			if err := json.Unmarshal([]byte(tt.raw), &s); err != nil {
+				t.Fatalf("failed to decode state: %v", err)
+			}
+
+			got := s.expand("light.kitchen")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expand() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEntityDiffApplyTo(t *testing.T) {
+	changed := time.Unix(1700000000, 0).UTC()
+	old := State{
+		EntityID:    "light.kitchen",
+		State:       "on",
+		Attributes:  map[string]interface{}{"brightness": float64(255), "color_mode": "xy"},
+		LastChanged: changed,
+		LastUpdated: changed,
+		Context:     Context{ID: "old", UserID: "user"},
+	}
+
+	tests := []struct {
+		name string
+		raw  string
+		want State
+	}{
+		{
+			name: "state and last_changed",
+			raw:  `{"+":{"s":"off","lc":1700000100}}`,
+			want: State{
+				EntityID:    "light.kitchen",
+				State:       "off",
+				Attributes:  old.Attributes,
+				LastChanged: time.Unix(1700000100, 0).UTC(),
+				LastUpdated: time.Unix(1700000100, 0).UTC(),
+				Context:     old.Context,
+			},
+		},
+		{
+			name: "last_updated only",
+			raw:  `{"+":{"lu":1700000200}}`,
+			want: State{
+				EntityID:    "light.kitchen",
+				State:       "on",
+				Attributes:  old.Attributes,
+				LastChanged: changed,
+				LastUpdated: time.Unix(1700000200, 0).UTC(),
+				Context:     old.Context,
+			},
+		},
+		{
+			name: "added and removed attributes",
+			raw:  `{"+":{"a":{"brightness":128}},"-":{"a":["color_mode"]}}`,
+			want: State{
+				EntityID:    "light.kitchen",
+				State:       "on",
+				Attributes:  map[string]interface{}{"brightness": float64(128)},
+				LastChanged: changed,
+				LastUpdated: changed,
+				Context:     old.Context,
+			},
+		},
+		{
+			name: "context ID only",
+			raw:  `{"+":{"c":"new"}}`,
+			want: State{
+				EntityID:    "light.kitchen",
+				State:       "on",
+				Attributes:  old.Attributes,
+				LastChanged: changed,
+				LastUpdated: changed,
+				Context:     Context{ID: "new", UserID: "user"},
+			},
+		},
+		{
+			name: "context parent only",
+			raw:  `{"+":{"c":{"parent_id":"parent"}}}`,
+			want: State{
+				EntityID:    "light.kitchen",
+				State:       "on",
+				Attributes:  old.Attributes,
+				LastChanged: changed,
+				LastUpdated: changed,
+				Context:     Context{ID: "old", ParentID: "parent", UserID: "user"},
+			},
+		},
+		{
+			name: "context with cleared user",
+			raw:  `{"+":{"c":{"user_id":null,"id":"new"}}}`,
+			want: State{
+				EntityID:    "light.kitchen",
+				State:       "on",
+				Attributes:  old.Attributes,
+				LastChanged: changed,
+				LastUpdated: changed,
+				Context:     Context{ID: "new"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var diff entityDiff
+			if err := json.Unmarshal([]byte(tt.raw), &diff); err != nil {
+				t.Fatalf("failed to decode diff: %v", err)
+			}
+
+			got := diff.applyTo(old)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("applyTo() = %+v, want %+v", got, tt.want)
+			}
+
+			// The old attributes may still be held by callers of the mirror
+			wantOld := map[string]interface{}{"brightness": float64(255), "color_mode": "xy"}
+			if !reflect.DeepEqual(old.Attributes, wantOld) {
+				t.Errorf("applyTo() modified the old attributes: %+v", old.Attributes)
+			}
+		})
+	}
+}
+
+func TestDecodeCompressedContext(t *testing.T) {
+	base := Context{ID: "old", ParentID: "parent", UserID: "user"}
+
+	tests := []struct {
+		name string
+		raw  string
+		want Context
+	}{
+		{name: "empty", raw: ``, want: base},
+		{name: "null", raw: `null`, want: base},
+		{name: "bare ID", raw: `"new"`, want: Context{ID: "new", ParentID: "parent", UserID: "user"}},
+		{
+			name: "full object",
+			raw:  `{"id":"new","parent_id":"other","user_id":"admin"}`,
+			want: Context{ID: "new", ParentID: "other", UserID: "admin"},
+		},
+		{name: "partial object", raw: `{"user_id":"admin"}`, want: Context{ID: "old", ParentID: "parent", UserID: "admin"}},
+		{name: "null field", raw: `{"parent_id":null}`, want: Context{ID: "old", UserID: "user"}},
+		{name: "invalid", raw: `42`, want: base},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decodeCompressedContext(json.RawMessage(tt.raw), base); got != tt.want {
+				t.Errorf("decodeCompressedContext(%q) = %+v, want %+v", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEntityMirrorWaitReady(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(c *WSClient, m *EntityMirror)
+		want  error
+	}{
+		{
+			name:  "snapshot received",
+			setup: func(c *WSClient, m *EntityMirror) { close(m.ready) },
+		},
+		{
+			name: "unsubscribed",
+			setup: func(c *WSClient, m *EntityMirror) {
+				m.Unsubscribe(context.Background())
+			},
+			want: ErrSubscriptionEnded,
+		},
+		{
+			name:  "client closed",
+			setup: func(c *WSClient, m *EntityMirror) { c.Close() },
+			want:  ErrClientClosed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewWSClient("ws://localhost:8123/api/websocket", "token")
+			m := &EntityMirror{
+				states: make(map[string]State),
+				ready:  make(chan struct{}),
+			}
+			m.Subscription = c.newSubscription(nil, func(msg *wsMessage) {})
+			m.id.Store(1)
+			c.subscriptions[1] = m.Subscription
+
+			tt.setup(c, m)
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			if err := m.WaitReady(ctx); !errors.Is(err, tt.want) {
+				t.Errorf("WaitReady() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package hago
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Sentinel errors matched by APIError through errors.Is
+var (
+	ErrBadRequest         = errors.New("bad request")
+	ErrUnauthorized       = errors.New("unauthorized")
+	ErrForbidden          = errors.New("forbidden")
+	ErrNotFound           = errors.New("not found")
+	ErrMethodNotAllowed   = errors.New("method not allowed")
+	ErrTooManyRequests    = errors.New("too many requests")
+	ErrServerError        = errors.New("server error")
+	ErrServiceUnavailable = errors.New("service unavailable")
+	ErrInvalidArgument    = errors.New("invalid argument")
+	ErrNotConnected       = errors.New("not connected to WebSocket")
+	ErrConnectionLost     = errors.New("WebSocket connection lost")
+	ErrAuthFailed         = errors.New("access token rejected")
+	ErrClientClosed       = errors.New("WebSocket client closed")
+	ErrSubscriptionEnded  = errors.New("subscription ended")
+)
+
+// maxErrorBody caps how much of an error response body is kept on APIError
+const maxErrorBody = 64 * 1024
+
+// APIError describes a non-successful response from the Home Assistant REST API
+type APIError struct {
+	StatusCode int
+	Method     string
+	Endpoint   string
+	Message    string
+	Body       []byte
+}
+
+// Error implements the error interface
+func (e *APIError) Error() string {
+	status := fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Message != "" {
+		return fmt.Sprintf("%s %s: %s: %s", e.Method, e.Endpoint, status, e.Message)
+	}
+	return fmt.Sprintf("%s %s: %s", e.Method, e.Endpoint, status)
+}
+
+// Is reports whether the error matches one of the sentinel errors for its status code
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrMethodNotAllowed:
+		return e.StatusCode == http.StatusMethodNotAllowed
+	case ErrTooManyRequests:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrServiceUnavailable:
+		return e.StatusCode == http.StatusServiceUnavailable
+	case ErrServerError:
+		return e.StatusCode >= 500
+	}
+	return false
+}
+
+// checkResponse returns nil if the response status is one of the expected codes,
+// otherwise it consumes the body and returns an *APIError describing the failure
+func checkResponse(resp *http.Response, expected ...int) error {
+	for _, code := range expected {
+		if resp.StatusCode == code {
+			return nil
+		}
+	}
+
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+	}
+	if resp.Request != nil {
+		apiErr.Method = resp.Request.Method
+		apiErr.Endpoint = resp.Request.URL.Path
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	apiErr.Body = body
+
+	// Home Assistant reports errors as {"message": "..."}, but some endpoints
+	// answer with plain text; HTML pages from proxies are left in Body only
+	var payload struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(body, &payload); err == nil && payload.Message != "" {
+		apiErr.Message = payload.Message
+	} else if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain") {
+		apiErr.Message = strings.TrimSpace(string(body))
+	}
+
+	return apiErr
+}
+
+// WSError is the error object Home Assistant returns for a failed WebSocket command
+type WSError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error implements the error interface
+func (e *WSError) Error() string {
+	if e.Message == "" {
+		return e.Code
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// Is maps well-known WebSocket error codes to the sentinel errors
+func (e *WSError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.Code == "not_found"
+	case ErrUnauthorized:
+		return e.Code == "unauthorized"
+	case ErrInvalidArgument:
+		return e.Code == "invalid_format"
+	}
+	return false
+}
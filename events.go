@@ -0,0 +1,240 @@
+package hago
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Core Home Assistant event types
+const (
+	EventStateChanged         = "state_changed"
+	EventCallService          = "call_service"
+	EventAutomationTriggered  = "automation_triggered"
+	EventHomeAssistantStart   = "homeassistant_start"
+	EventHomeAssistantStarted = "homeassistant_started"
+	EventHomeAssistantStop    = "homeassistant_stop"
+	EventServiceRegistered    = "service_registered"
+	EventComponentLoaded      = "component_loaded"
+)
+
+// StateChangedEvent is fired when the state of an entity changes
+type StateChangedEvent struct {
+	Event
+	EntityID string
+	// OldState is nil when the entity was added
+	OldState *State
+	// NewState is nil when the entity was removed
+	NewState *State
+}
+
+// CallServiceEvent is fired when a service is called
+type CallServiceEvent struct {
+	Event
+	Domain      string
+	Service     string
+	ServiceData map[string]interface{}
+}
+
+// AutomationTriggeredEvent is fired when an automation is triggered
+type AutomationTriggeredEvent struct {
+	Event
+	Name     string
+	EntityID string
+	// Source describes what triggered the automation
+	Source string
+}
+
+// HomeAssistantStartEvent is fired when Home Assistant starts
+type HomeAssistantStartEvent struct {
+	Event
+}
+
+// HomeAssistantStartedEvent is fired once all integrations have been set up
+type HomeAssistantStartedEvent struct {
+	Event
+}
+
+// HomeAssistantStopEvent is fired when Home Assistant shuts down
+type HomeAssistantStopEvent struct {
+	Event
+}
+
+// ServiceRegisteredEvent is fired when a new service is registered
+type ServiceRegisteredEvent struct {
+	Event
+	Domain  string
+	Service string
+}
+
+// ComponentLoadedEvent is fired when an integration has been set up
+type ComponentLoadedEvent struct {
+	Event
+	Component string
+}
+
+// EventDecoder converts a generic event into a typed event
+type EventDecoder func(event Event) (interface{}, error)
+
+// eventDecoders maps event types to their decoders
+var eventDecoders = struct {
+	sync.RWMutex
+	m map[string]EventDecoder
+}{
+	m: map[string]EventDecoder{
+		EventStateChanged:         decodeStateChanged,
+		EventCallService:          decodeCallService,
+		EventAutomationTriggered:  decodeAutomationTriggered,
+		EventHomeAssistantStart:   func(e Event) (interface{}, error) { return &HomeAssistantStartEvent{Event: e}, nil },
+		EventHomeAssistantStarted: func(e Event) (interface{}, error) { return &HomeAssistantStartedEvent{Event: e}, nil },
+		EventHomeAssistantStop:    func(e Event) (interface{}, error) { return &HomeAssistantStopEvent{Event: e}, nil },
+		EventServiceRegistered:    decodeServiceRegistered,
+		EventComponentLoaded:      decodeComponentLoaded,
+	},
+}
+
+// RegisterEventDecoder registers the decoder used by DecodeEvent for an event
+// type, e.g. for events fired by custom integrations. It replaces any decoder
+// registered for the type before, including the built-in ones.
+func RegisterEventDecoder(eventType string, decoder EventDecoder) {
+	eventDecoders.Lock()
+	defer eventDecoders.Unlock()
+
+	eventDecoders.m[eventType] = decoder
+}
+
+// DecodeEvent converts an event into the typed event registered for its type,
+// such as *StateChangedEvent. Events without a decoder are returned as *Event,
+// so the result is always a pointer.
+func DecodeEvent(event Event) (interface{}, error) {
+	eventDecoders.RLock()
+	decoder, ok := eventDecoders.m[event.EventType]
+	eventDecoders.RUnlock()
+
+	if !ok {
+		return &event, nil
+	}
+
+	typed, err := decoder(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s event: %w", event.EventType, err)
+	}
+	return typed, nil
+}
+
+// EventFromMessage extracts the event from a raw WebSocket message as received
+// by handlers registered with AddEventHandler
+func EventFromMessage(msg map[string]interface{}) (Event, error) {
+	var event Event
+	raw, ok := msg["event"]
+	if !ok {
+		return event, fmt.Errorf("%w: message has no event", ErrInvalidArgument)
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return event, err
+	}
+	if err := json.Unmarshal(data, &event); err != nil {
+		return event, err
+	}
+	return event, nil
+}
+
+// DecodeData decodes the event data into v
+func (e Event) DecodeData(v interface{}) error {
+	data, err := json.Marshal(e.EventData)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// decodeStateChanged decodes a state_changed event
+func decodeStateChanged(e Event) (interface{}, error) {
+	var data struct {
+		EntityID string `json:"entity_id"`
+		OldState *State `json:"old_state"`
+		NewState *State `json:"new_state"`
+	}
+	if err := e.DecodeData(&data); err != nil {
+		return nil, err
+	}
+
+	return &StateChangedEvent{
+		Event:    e,
+		EntityID: data.EntityID,
+		OldState: data.OldState,
+		NewState: data.NewState,
+	}, nil
+}
+
+// decodeCallService decodes a call_service event
+func decodeCallService(e Event) (interface{}, error) {
+	var data struct {
+		Domain      string                 `json:"domain"`
+		Service     string                 `json:"service"`
+		ServiceData map[string]interface{} `json:"service_data"`
+	}
+	if err := e.DecodeData(&data); err != nil {
+		return nil, err
+	}
+
+	return &CallServiceEvent{
+		Event:       e,
+		Domain:      data.Domain,
+		Service:     data.Service,
+		ServiceData: data.ServiceData,
+	}, nil
+}
+
+// decodeAutomationTriggered decodes an automation_triggered event
+func decodeAutomationTriggered(e Event) (interface{}, error) {
+	var data struct {
+		Name     string `json:"name"`
+		EntityID string `json:"entity_id"`
+		Source   string `json:"source"`
+	}
+	if err := e.DecodeData(&data); err != nil {
+		return nil, err
+	}
+
+	return &AutomationTriggeredEvent{
+		Event:    e,
+		Name:     data.Name,
+		EntityID: data.EntityID,
+		Source:   data.Source,
+	}, nil
+}
+
+// decodeServiceRegistered decodes a service_registered event
+func decodeServiceRegistered(e Event) (interface{}, error) {
+	var data struct {
+		Domain  string `json:"domain"`
+		Service string `json:"service"`
+	}
+	if err := e.DecodeData(&data); err != nil {
+		return nil, err
+	}
+
+	return &ServiceRegisteredEvent{
+		Event:   e,
+		Domain:  data.Domain,
+		Service: data.Service,
+	}, nil
+}
+
+// decodeComponentLoaded decodes a component_loaded event
+func decodeComponentLoaded(e Event) (interface{}, error) {
+	var data struct {
+		Component string `json:"component"`
+	}
+	if err := e.DecodeData(&data); err != nil {
+		return nil, err
+	}
+
+	return &ComponentLoadedEvent{
+		Event:     e,
+		Component: data.Component,
+	}, nil
+}
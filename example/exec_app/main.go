@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -41,29 +42,30 @@ func main() {
 
 	// 创建API实例
 	api := hago.NewAPI(client)
+	ctx := context.Background()
 
 	// 检查API是否可用
-	if err := api.CheckAPI(); err != nil {
+	if err := api.CheckAPI(ctx); err != nil {
 		log.Fatalf("API不可用: %v", err)
 	}
 	fmt.Println("Home Assistant API 连接成功!")
 
 	// 获取配置信息
-	config, err := api.GetConfig()
+	config, err := api.GetConfig(ctx)
 	if err != nil {
 		log.Fatalf("获取配置失败: %v", err)
 	}
 	fmt.Printf("Home Assistant 版本: %v\n", config["version"])
 
 	// 获取所有实体状态
-	states, err := api.GetStates()
+	states, err := api.GetStates(ctx)
 	if err != nil {
 		log.Fatalf("获取实体状态失败: %v", err)
 	}
 	fmt.Printf("找到 %d 个实体\n", len(states))
 
 	// 获取可用的服务
-	services, err := api.GetServices()
+	services, err := api.GetServices(ctx)
 	if err != nil {
 		log.Fatalf("获取服务列表失败: %v", err)
 	}
@@ -91,7 +93,7 @@ func main() {
 	}
 
 	// 交互式操作菜单
-	interactiveMenu(api, integrations, integrationNames)
+	interactiveMenu(ctx, api, integrations, integrationNames)
 }
 
 // 收集集成信息
@@ -203,7 +205,7 @@ func collectIntegrationInfo(states []hago.State, services map[string]map[string]
 }
 
 // 交互式菜单
-func interactiveMenu(api *hago.API, integrations map[string]IntegrationInfo, integrationNames []string) {
+func interactiveMenu(ctx context.Context, api *hago.API, integrations map[string]IntegrationInfo, integrationNames []string) {
 	reader := bufio.NewReader(os.Stdin)
 
 	for {
@@ -221,9 +223,9 @@ func interactiveMenu(api *hago.API, integrations map[string]IntegrationInfo, int
 		case "1":
 			viewIntegrationDetails(reader, integrations, integrationNames)
 		case "2":
-			callIntegrationService(reader, api, integrations, integrationNames)
+			callIntegrationService(ctx, reader, api, integrations, integrationNames)
 		case "3":
-			queryEntityState(reader, api, integrations)
+			queryEntityState(ctx, reader, api, integrations)
 		case "4":
 			fmt.Println("程序退出")
 			return
@@ -299,7 +301,7 @@ func viewIntegrationDetails(reader *bufio.Reader, integrations map[string]Integr
 }
 
 // 调用集成服务
-func callIntegrationService(reader *bufio.Reader, api *hago.API, integrations map[string]IntegrationInfo, integrationNames []string) {
+func callIntegrationService(ctx context.Context, reader *bufio.Reader, api *hago.API, integrations map[string]IntegrationInfo, integrationNames []string) {
 	fmt.Println("\n====== 调用集成服务 ======")
 
 	// 仅显示有服务的集成
@@ -393,16 +395,19 @@ func callIntegrationService(reader *bufio.Reader, api *hago.API, integrations ma
 	fmt.Printf("\n正在调用服务: %s.%s\n", integrationName, serviceName)
 	fmt.Printf("参数: %v\n", serviceData)
 
-	err = api.CallService(integrationName, serviceName, serviceData)
+	changed, err := api.CallService(ctx, integrationName, serviceName, hago.Target{}, serviceData)
 	if err != nil {
 		fmt.Printf("调用服务失败: %v\n", err)
 	} else {
-		fmt.Println("服务调用成功!")
+		fmt.Printf("服务调用成功! %d个实体状态发生变化\n", len(changed))
+		for _, state := range changed {
+			fmt.Printf("  %s: %s\n", state.EntityID, state.State)
+		}
 	}
 }
 
 // 查询实体状态
-func queryEntityState(reader *bufio.Reader, api *hago.API, integrations map[string]IntegrationInfo) {
+func queryEntityState(ctx context.Context, reader *bufio.Reader, api *hago.API, integrations map[string]IntegrationInfo) {
 	fmt.Println("\n====== 查询实体状态 ======")
 	fmt.Print("请输入实体ID (例如 light.living_room): ")
 
@@ -414,7 +419,7 @@ func queryEntityState(reader *bufio.Reader, api *hago.API, integrations map[stri
 		return
 	}
 
-	state, err := api.GetState(entityID)
+	state, err := api.GetState(ctx, entityID)
 	if err != nil {
 		fmt.Printf("获取状态失败: %v\n", err)
 		return
@@ -469,14 +474,9 @@ func queryEntityState(reader *bufio.Reader, api *hago.API, integrations map[stri
 
 				domain, service := serviceParts[0], serviceParts[1]
 
-				// 准备服务数据
-				serviceData := map[string]interface{}{
-					"entity_id": entityID,
-				}
-
 				// 调用服务
 				fmt.Printf("调用服务: %s.%s 用于实体 %s\n", domain, service, entityID)
-				err = api.CallService(domain, service, serviceData)
+				_, err = api.CallService(ctx, domain, service, hago.TargetEntities(entityID), nil)
 				if err != nil {
 					fmt.Printf("调用服务失败: %v\n", err)
 				} else {
@@ -484,7 +484,7 @@ func queryEntityState(reader *bufio.Reader, api *hago.API, integrations map[stri
 
 					// 再次获取状态以显示更改
 					time.Sleep(1 * time.Second) // 给Home Assistant一点时间来处理
-					newState, err := api.GetState(entityID)
+					newState, err := api.GetState(ctx, entityID)
 					if err == nil && newState.State != state.State {
 						fmt.Printf("实体状态已从 '%s' 变为 '%s'\n", state.State, newState.State)
 					}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -16,7 +17,8 @@ func main() {
 	// Replace with your Home Assistant URL and API token
 	baseURL := "http://192.168.199.130:8123/"
 	apiToken := os.Getenv("HATOKEN")
-	// wsURL变量已移除，因为当前不使用WebSocket功能
+	// 设备、区域和楼层信息通过WebSocket从注册表读取
+	wsURL := strings.Replace(strings.TrimSuffix(baseURL, "/"), "http", "ws", 1) + "/api/websocket"
 
 	// Create a new Home Assistant client
 	client, err := hago.NewClient(baseURL, apiToken)
@@ -26,39 +28,63 @@ func main() {
 
 	// Create an API instance
 	api := hago.NewAPI(client)
+	ctx := context.Background()
 
 	// Check if the API is available
-	if err := api.CheckAPI(); err != nil {
+	if err := api.CheckAPI(ctx); err != nil {
 		log.Fatalf("API is not available: %v", err)
 	}
 	log.Println("Home Assistant API is available!")
 
 	// Get configuration
-	config, err := api.GetConfig()
+	config, err := api.GetConfig(ctx)
 	if err != nil {
 		log.Fatalf("Failed to get config: %v", err)
 	}
 	log.Printf("Home Assistant version: %v", config["version"])
 
 	// Get all entity states
-	states, err := api.GetStates()
+	states, err := api.GetStates(ctx)
 	if err != nil {
 		log.Fatalf("Failed to get states: %v", err)
 	}
 	log.Printf("Found %d entities", len(states))
 
+	// 连接WebSocket并加载实体、设备、区域和楼层注册表
+	wsClient := hago.NewWSClient(wsURL, apiToken)
+	if err := wsClient.Connect(ctx); err != nil {
+		log.Fatalf("Failed to connect to WebSocket: %v", err)
+	}
+	defer wsClient.Close()
+
+	topology, err := wsClient.LoadTopology(ctx)
+	if err != nil {
+		log.Fatalf("Failed to load topology: %v", err)
+	}
+
 	// 1. 按照"集成-设备-实体"的顺序组织数据
 
 	// 首先，创建一个集成 -> 设备 -> 实体的映射
 	integrationDeviceMap := make(map[string]map[string][]hago.State)
+	// 设备ID -> 显示用的设备标签
+	deviceLabels := make(map[string]string)
 
 	// 按照集成和设备ID对实体进行分组
 	for _, state := range states {
-		// 获取集成名称
+		info, _ := topology.Entity(state.EntityID)
+
+		// 获取集成名称，优先使用实体注册表中的平台
 		integrationName := getIntegrationNameFromEntity(state)
+		if info.Entity != nil && info.Entity.Platform != "" {
+			integrationName = info.Entity.Platform
+		}
 
-		// 获取设备ID（从实体ID或属性中推断）
-		deviceID := getDeviceIDFromEntity(state)
+		// 按设备注册表中的设备ID分组，未关联设备的实体归入同一组
+		deviceID := ""
+		if info.Device != nil {
+			deviceID = info.Device.ID
+		}
+		deviceLabels[deviceID] = getDeviceLabel(info)
 
 		// 确保集成映射存在
 		if _, ok := integrationDeviceMap[integrationName]; !ok {
@@ -118,7 +144,7 @@ func main() {
 	for _, name := range importantIntegrations {
 		for _, integration := range integrationNames {
 			if strings.Contains(strings.ToLower(integration), strings.ToLower(name)) && !processedIntegrations[integration] {
-				displayIntegrationDetails(integration, integrationDeviceMap[integration])
+				displayIntegrationDetails(integration, integrationDeviceMap[integration], deviceLabels)
 				processedIntegrations[integration] = true
 			}
 		}
@@ -127,7 +153,7 @@ func main() {
 	// 然后显示其余的集成
 	for _, integration := range integrationNames {
 		if !processedIntegrations[integration] {
-			displayIntegrationDetails(integration, integrationDeviceMap[integration])
+			displayIntegrationDetails(integration, integrationDeviceMap[integration], deviceLabels)
 		}
 	}
 
@@ -140,97 +166,21 @@ func main() {
 	fmt.Println("正在关闭...")
 }
 
-// 从实体推断设备ID
-func getDeviceIDFromEntity(state hago.State) string {
-	// 首先检查属性中是否有设备ID
-	if deviceID, ok := state.Attributes["device_id"].(string); ok && deviceID != "" {
-		return deviceID
-	}
-
-	// 如果没有直接的设备ID，则从实体ID中推断
-	parts := strings.SplitN(state.EntityID, ".", 2)
-	if len(parts) < 2 {
+// 根据设备注册表生成设备标签，包含所在区域和楼层
+func getDeviceLabel(info hago.EntityInfo) string {
+	if info.Device == nil {
 		return "未知设备"
 	}
 
-	domain := parts[0]
-	entityID := parts[1]
-
-	// 对于某些集成，可以通过特定前缀或模式识别设备
-	// 例如：xiaomi_cn_540583230_eaffh1_state_playing_p_7_1
-	// 可以提取出 xiaomi_cn_540583230_eaffh1 作为设备ID
-
-	// 提取可能的设备标识符
-	deviceIdentifier := ""
-
-	// 先检查友好名称中的设备名称
-	if friendlyName, ok := state.Attributes["friendly_name"].(string); ok && friendlyName != "" {
-		// 如果友好名称中包含设备名称与属性名称的分隔，提取设备部分
-		nameParts := strings.Split(friendlyName, "  ")
-		if len(nameParts) > 1 {
-			deviceIdentifier = nameParts[0]
-			return deviceIdentifier
-		}
-	}
-
-	// 常见的设备ID模式
-	patterns := []struct {
-		prefix string
-		parts  int
-	}{
-		{"xiaomi_cn_", 4},    // xiaomi_cn_<id>_<model>
-		{"zhimi_cn_", 4},     // zhimi_cn_<id>_<model>
-		{"opple_cn_", 4},     // opple_cn_<id>_<model>
-		{"deye_", 1},         // deye_<id>
-		{"miaomiaoc_cn_", 5}, // miaomiaoc_cn_<type>_<id>_<model>
-		{"090615_cn_", 4},    // 090615_cn_<id>_<model>
-		{"fawad_cn_", 4},     // fawad_cn_<id>_<model>
-	}
-
-	for _, pattern := range patterns {
-		if strings.HasPrefix(entityID, pattern.prefix) {
-			idParts := strings.SplitN(entityID, "_", pattern.parts+1)
-			if len(idParts) > pattern.parts {
-				// 构建设备ID前缀
-				devicePrefix := strings.Join(idParts[:pattern.parts], "_")
-
-				// 如果entityID中包含设备类型或其他信息，则继续细分
-				if extraParts := strings.SplitN(idParts[pattern.parts], "_p_", 2); len(extraParts) > 1 {
-					deviceIdentifier = devicePrefix + "_" + extraParts[0]
-				} else {
-					deviceIdentifier = devicePrefix
-				}
-
-				return deviceIdentifier
-			}
-		}
-	}
-
-	// 对于特殊设备，使用特定识别方法
-	if domain == "climate" && strings.HasPrefix(entityID, "090615_cn_proxy") {
-		parts := strings.Split(entityID, "_")
-		if len(parts) >= 5 {
-			// 提取代理后面的数字部分作为设备标识符
-			return "空调" + parts[4]
+	label := info.Device.DisplayName()
+	if info.Area != nil {
+		location := info.Area.Name
+		if info.Floor != nil {
+			location = info.Floor.Name + " / " + location
 		}
+		label += " @ " + location
 	}
-
-	// 如果无法识别特定模式，使用最简单的方法：使用整个实体ID或其一部分
-	// 优先提取实体ID中可能的设备部分
-	if deviceParts := strings.SplitN(entityID, "_p_", 2); len(deviceParts) > 1 {
-		return domain + "." + deviceParts[0]
-	}
-
-	// 最后的后备方案：使用实体域和前缀作为设备ID
-	// 将同类型且前缀相似的实体分到一起
-	for _, prefix := range []string{"xiaomi", "tuya", "deye", "zhimi", "opple", "fawad", "090615"} {
-		if strings.Contains(entityID, prefix) {
-			return domain + "." + prefix
-		}
-	}
-
-	// 如果都没有匹配，使用域名作为设备ID
-	return domain
+	return label
 }
 
 // 从entity_id推断集成名称
@@ -333,27 +283,33 @@ func getIntegrationNameFromEntity(state hago.State) string {
 }
 
 // 显示集成详细信息，按设备和实体层次
-func displayIntegrationDetails(integration string, deviceMap map[string][]hago.State) {
+func displayIntegrationDetails(integration string, deviceMap map[string][]hago.State, deviceLabels map[string]string) {
 	fmt.Printf("\n## 集成: %s (%d个设备)\n", integration, len(deviceMap))
 
-	// 获取所有设备ID并排序
+	// 获取所有设备ID并按设备标签排序，同名设备仍按ID区分
 	var deviceIDs []string
 	for id := range deviceMap {
 		deviceIDs = append(deviceIDs, id)
 	}
-	sort.Strings(deviceIDs)
+	sort.Slice(deviceIDs, func(i, j int) bool {
+		if deviceLabels[deviceIDs[i]] != deviceLabels[deviceIDs[j]] {
+			return deviceLabels[deviceIDs[i]] < deviceLabels[deviceIDs[j]]
+		}
+		return deviceIDs[i] < deviceIDs[j]
+	})
 
 	// 显示每个设备及其实体
 	for _, deviceID := range deviceIDs {
 		entities := deviceMap[deviceID]
+		deviceLabel := deviceLabels[deviceID]
 
 		// 提取设备友好名称
 		deviceName := getDeviceFriendlyName(entities)
 
-		if deviceName != "" && deviceName != deviceID {
-			fmt.Printf("\n  🔶 设备: %s (%s) - %d个实体\n", deviceID, deviceName, len(entities))
+		if deviceName != "" && deviceName != deviceLabel {
+			fmt.Printf("\n  🔶 设备: %s (%s) - %d个实体\n", deviceLabel, deviceName, len(entities))
 		} else {
-			fmt.Printf("\n  🔶 设备: %s - %d个实体\n", deviceID, len(entities))
+			fmt.Printf("\n  🔶 设备: %s - %d个实体\n", deviceLabel, len(entities))
 		}
 
 		// 将实体按域名分类
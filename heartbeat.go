@@ -0,0 +1,63 @@
+package hago
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Default heartbeat settings used when WithHeartbeat is not given
+const (
+	defaultHeartbeatInterval = 30 * time.Second
+	defaultHeartbeatTimeout  = 10 * time.Second
+)
+
+// heartbeat pings Home Assistant on conn at the configured interval. A ping
+// that is not answered in time means the connection is stale: it is closed,
+// which makes the reader go through the reconnect path. The heartbeat stops
+// when the client is closed or conn is replaced.
+func (c *WSClient) heartbeat(conn *websocket.Conn) {
+	timeout := c.heartbeatTimeout
+	if timeout <= 0 {
+		timeout = c.heartbeatInterval
+	}
+
+	ticker := time.NewTicker(c.heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+
+		c.mu.Lock()
+		current := c.connected && c.conn == conn
+		c.mu.Unlock()
+		if !current {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		_, err := c.Call(ctx, map[string]interface{}{
+			"type": "ping",
+		})
+		cancel()
+
+		if err == nil {
+			continue
+		}
+		// The reader already noticed the connection is gone, or the client was closed
+		if errors.Is(err, ErrConnectionLost) || errors.Is(err, ErrNotConnected) || errors.Is(err, ErrClientClosed) {
+			return
+		}
+
+		log.Printf("WebSocket heartbeat failed, closing stale connection: %v", err)
+		conn.Close()
+		return
+	}
+}
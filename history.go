@@ -0,0 +1,147 @@
+package hago
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// HistoryOptions selects the states returned by GetHistory
+type HistoryOptions struct {
+	// StartTime is the beginning of the window; zero means one day before now,
+	// whatever EndTime is
+	StartTime time.Time
+	// EndTime is the end of the window; zero means one day after StartTime
+	EndTime time.Time
+	// EntityIDs are the entities to return; Home Assistant requires at least one
+	EntityIDs []string
+	// MinimalResponse only returns state and last_changed for all but the
+	// first and last state of each entity
+	MinimalResponse bool
+	// NoAttributes skips attributes, which speeds up large queries
+	NoAttributes bool
+	// SignificantChangesOnly skips attribute-only changes for most domains.
+	// Unlike the REST endpoint default, the zero value returns every change.
+	SignificantChangesOnly bool
+}
+
+// GetHistory returns the state history of entities in a time window, keyed by entity ID.
+// The first state of each series is the state the entity had at StartTime.
+func (a *API) GetHistory(ctx context.Context, opts HistoryOptions) (map[string][]State, error) {
+	if len(opts.EntityIDs) == 0 {
+		return nil, fmt.Errorf("%w: at least one entity ID is required", ErrInvalidArgument)
+	}
+
+	path := "/api/history/period"
+	if !opts.StartTime.IsZero() {
+		path += "/" + url.PathEscape(formatTime(opts.StartTime))
+	}
+
+	query := url.Values{}
+	query.Set("filter_entity_id", strings.Join(opts.EntityIDs, ","))
+	if !opts.EndTime.IsZero() {
+		query.Set("end_time", formatTime(opts.EndTime))
+	}
+	if opts.MinimalResponse {
+		query.Set("minimal_response", "")
+	}
+	if opts.NoAttributes {
+		query.Set("no_attributes", "")
+	}
+	if opts.SignificantChangesOnly {
+		query.Set("significant_changes_only", "1")
+	} else {
+		query.Set("significant_changes_only", "0")
+	}
+
+	resp, err := a.client.Get(ctx, path+"?"+query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp, http.StatusOK); err != nil {
+		return nil, fmt.Errorf("failed to get history: %w", err)
+	}
+
+	var series [][]State
+	if err := json.NewDecoder(resp.Body).Decode(&series); err != nil {
+		return nil, err
+	}
+
+	history := make(map[string][]State, len(series))
+	for _, states := range series {
+		if len(states) == 0 {
+			continue
+		}
+
+		// Minimal responses only carry the entity ID on the first state
+		entityID := states[0].EntityID
+		for i := range states {
+			if states[i].EntityID == "" {
+				states[i].EntityID = entityID
+			}
+			if states[i].LastUpdated.IsZero() {
+				states[i].LastUpdated = states[i].LastChanged
+			}
+		}
+
+		history[entityID] = append(history[entityID], states...)
+	}
+
+	return history, nil
+}
+
+// WalkHistory splits the window of opts into chunks of the given size and calls fn
+// with the history of each chunk in chronological order. A zero EndTime means now.
+// Iteration stops at the first error returned by the API or fn.
+//
+// Each chunk starts with the state the entity had at the chunk's start time,
+// so the boundary state may appear in two consecutive chunks.
+func (a *API) WalkHistory(ctx context.Context, opts HistoryOptions, chunk time.Duration, fn func(start, end time.Time, history map[string][]State) error) error {
+	if opts.StartTime.IsZero() {
+		return fmt.Errorf("%w: start time is required", ErrInvalidArgument)
+	}
+	if len(opts.EntityIDs) == 0 {
+		return fmt.Errorf("%w: at least one entity ID is required", ErrInvalidArgument)
+	}
+	if chunk <= 0 {
+		return fmt.Errorf("%w: chunk size must be positive", ErrInvalidArgument)
+	}
+
+	end := opts.EndTime
+	if end.IsZero() {
+		end = time.Now()
+	}
+
+	for start := opts.StartTime; start.Before(end); start = start.Add(chunk) {
+		chunkEnd := start.Add(chunk)
+		if chunkEnd.After(end) {
+			chunkEnd = end
+		}
+
+		chunkOpts := opts
+		chunkOpts.StartTime = start
+		chunkOpts.EndTime = chunkEnd
+
+		history, err := a.GetHistory(ctx, chunkOpts)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(start, chunkEnd, history); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// formatTime formats a timestamp the way Home Assistant expects in URLs
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
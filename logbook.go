@@ -0,0 +1,58 @@
+package hago
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// LogbookOptions selects the entries returned by GetLogbook
+type LogbookOptions struct {
+	// StartTime is the beginning of the window; zero means the start of the
+	// current day in the time zone of the Home Assistant server
+	StartTime time.Time
+	// EndTime is the end of the window; zero means one day after StartTime
+	EndTime time.Time
+	// EntityIDs limits the result to entries about the given entities
+	EntityIDs []string
+}
+
+// GetLogbook returns logbook entries in a time window, oldest first
+func (a *API) GetLogbook(ctx context.Context, opts LogbookOptions) ([]LogbookEntry, error) {
+	path := "/api/logbook"
+	if !opts.StartTime.IsZero() {
+		path += "/" + url.PathEscape(formatTime(opts.StartTime))
+	}
+
+	query := url.Values{}
+	if len(opts.EntityIDs) > 0 {
+		query.Set("entity", strings.Join(opts.EntityIDs, ","))
+	}
+	if !opts.EndTime.IsZero() {
+		query.Set("end_time", formatTime(opts.EndTime))
+	}
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	resp, err := a.client.Get(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp, http.StatusOK); err != nil {
+		return nil, fmt.Errorf("failed to get logbook: %w", err)
+	}
+
+	var entries []LogbookEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
@@ -28,13 +28,24 @@ type Service struct {
 	Data    map[string]interface{} `json:"data,omitempty"`
 }
 
+// ServiceCallResult is the outcome of a service call
+type ServiceCallResult struct {
+	// ChangedStates lists the states changed by the call (REST API only)
+	ChangedStates []State `json:"changed_states"`
+	// Response holds the data returned by services that support responses
+	Response map[string]interface{} `json:"service_response,omitempty"`
+	// Context is the context the service ran in (WebSocket API only)
+	Context *Context `json:"context,omitempty"`
+}
+
 // Domain represents a Home Assistant domain with its services
 type Domain struct {
 	Domain   string              `json:"domain"`
 	Services map[string][]string `json:"services"`
 }
 
-// Entity represents a Home Assistant entity with its metadata
+// Entity represents a Home Assistant entity with its metadata. The registry
+// fields are filled by the entity registry commands of WSClient.
 type Entity struct {
 	EntityID     string                 `json:"entity_id"`
 	Name         string                 `json:"name"`
@@ -47,43 +58,109 @@ type Entity struct {
 	AreaID       string                 `json:"area_id,omitempty"`
 	Platform     string                 `json:"platform,omitempty"`
 	Capabilities map[string]interface{} `json:"capabilities,omitempty"`
+
+	// Entity registry fields
+	ID                  string                 `json:"id,omitempty"`
+	UniqueID            string                 `json:"unique_id,omitempty"`
+	ConfigEntryID       string                 `json:"config_entry_id,omitempty"`
+	OriginalName        string                 `json:"original_name,omitempty"`
+	HasEntityName       bool                   `json:"has_entity_name,omitempty"`
+	Icon                string                 `json:"icon,omitempty"`
+	OriginalIcon        string                 `json:"original_icon,omitempty"`
+	DeviceClass         string                 `json:"device_class,omitempty"`
+	OriginalDeviceClass string                 `json:"original_device_class,omitempty"`
+	EntityCategory      string                 `json:"entity_category,omitempty"`
+	DisabledBy          string                 `json:"disabled_by,omitempty"`
+	HiddenBy            string                 `json:"hidden_by,omitempty"`
+	TranslationKey      string                 `json:"translation_key,omitempty"`
+	Labels              []string               `json:"labels,omitempty"`
+	Aliases             []string               `json:"aliases,omitempty"`
+	Options             map[string]interface{} `json:"options,omitempty"`
+}
+
+// Disabled reports whether the entity is disabled in the entity registry
+func (e Entity) Disabled() bool {
+	return e.DisabledBy != ""
+}
+
+// Hidden reports whether the entity is hidden in the entity registry
+func (e Entity) Hidden() bool {
+	return e.HiddenBy != ""
 }
 
 // Event represents a Home Assistant event
 type Event struct {
 	EventType string                 `json:"event_type"`
-	EventData map[string]interface{} `json:"event_data"`
+	EventData map[string]interface{} `json:"data"`
 	Origin    string                 `json:"origin"`
 	TimeFired time.Time              `json:"time_fired"`
 	Context   Context                `json:"context"`
 }
 
-// Device represents a Home Assistant device
+// Device represents a Home Assistant device as stored in the device registry
 type Device struct {
-	ID               string    `json:"id"`
-	AreaID           string    `json:"area_id,omitempty"`
-	Name             string    `json:"name"`
-	Manufacturer     string    `json:"manufacturer,omitempty"`
-	Model            string    `json:"model,omitempty"`
-	Identifiers      []string  `json:"identifiers,omitempty"`
-	ViaDevice        string    `json:"via_device,omitempty"`
-	LastSeen         time.Time `json:"last_seen,omitempty"`
-	NameByUser       string    `json:"name_by_user,omitempty"`
-	SWVersion        string    `json:"sw_version,omitempty"`
-	HWVersion        string    `json:"hw_version,omitempty"`
-	EntryType        string    `json:"entry_type,omitempty"`
-	DisabledBy       string    `json:"disabled_by,omitempty"`
-	ConfigurationURL string    `json:"configuration_url,omitempty"`
+	ID                 string      `json:"id"`
+	AreaID             string      `json:"area_id,omitempty"`
+	Name               string      `json:"name"`
+	Manufacturer       string      `json:"manufacturer,omitempty"`
+	Model              string      `json:"model,omitempty"`
+	ModelID            string      `json:"model_id,omitempty"`
+	SerialNumber       string      `json:"serial_number,omitempty"`
+	Identifiers        [][2]string `json:"identifiers,omitempty"`
+	Connections        [][2]string `json:"connections,omitempty"`
+	ConfigEntries      []string    `json:"config_entries,omitempty"`
+	PrimaryConfigEntry string      `json:"primary_config_entry,omitempty"`
+	ViaDeviceID        string      `json:"via_device_id,omitempty"`
+	LastSeen           time.Time   `json:"last_seen,omitempty"`
+	NameByUser         string      `json:"name_by_user,omitempty"`
+	SWVersion          string      `json:"sw_version,omitempty"`
+	HWVersion          string      `json:"hw_version,omitempty"`
+	EntryType          string      `json:"entry_type,omitempty"`
+	DisabledBy         string      `json:"disabled_by,omitempty"`
+	ConfigurationURL   string      `json:"configuration_url,omitempty"`
+	Labels             []string    `json:"labels,omitempty"`
+}
+
+// DisplayName returns the name given by the user, or the integration's name
+func (d Device) DisplayName() string {
+	if d.NameByUser != "" {
+		return d.NameByUser
+	}
+	return d.Name
 }
 
 // Area represents a Home Assistant area
 type Area struct {
-	ID      string   `json:"id"`
-	Name    string   `json:"name"`
-	Picture string   `json:"picture,omitempty"`
+	ID                  string   `json:"area_id"`
+	Name                string   `json:"name"`
+	FloorID             string   `json:"floor_id,omitempty"`
+	Icon                string   `json:"icon,omitempty"`
+	Picture             string   `json:"picture,omitempty"`
+	Aliases             []string `json:"aliases,omitempty"`
+	Labels              []string `json:"labels,omitempty"`
+	TemperatureEntityID string   `json:"temperature_entity_id,omitempty"`
+	HumidityEntityID    string   `json:"humidity_entity_id,omitempty"`
+}
+
+// Floor represents a floor of a building, grouping areas
+type Floor struct {
+	ID   string `json:"floor_id"`
+	Name string `json:"name"`
+	// Level orders the floors, 0 being the ground floor; nil when not set
+	Level   *int     `json:"level,omitempty"`
+	Icon    string   `json:"icon,omitempty"`
 	Aliases []string `json:"aliases,omitempty"`
 }
 
+// Label represents a label that can be assigned to areas, devices and entities
+type Label struct {
+	ID          string `json:"label_id"`
+	Name        string `json:"name"`
+	Icon        string `json:"icon,omitempty"`
+	Color       string `json:"color,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
 // User represents a Home Assistant user
 type User struct {
 	ID          string   `json:"id"`
@@ -92,3 +169,34 @@ type User struct {
 	IsAdmin     bool     `json:"is_admin"`
 	Credentials []string `json:"credentials"`
 }
+
+// LogbookEntry represents an entry of the Home Assistant logbook
+type LogbookEntry struct {
+	When     time.Time `json:"when"`
+	Name     string    `json:"name,omitempty"`
+	Message  string    `json:"message,omitempty"`
+	Domain   string    `json:"domain,omitempty"`
+	EntityID string    `json:"entity_id,omitempty"`
+	State    string    `json:"state,omitempty"`
+	Icon     string    `json:"icon,omitempty"`
+
+	// Information about what caused the entry
+	ContextID           string `json:"context_id,omitempty"`
+	ContextUserID       string `json:"context_user_id,omitempty"`
+	ContextEventType    string `json:"context_event_type,omitempty"`
+	ContextDomain       string `json:"context_domain,omitempty"`
+	ContextService      string `json:"context_service,omitempty"`
+	ContextEntityID     string `json:"context_entity_id,omitempty"`
+	ContextEntityIDName string `json:"context_entity_id_name,omitempty"`
+	ContextName         string `json:"context_name,omitempty"`
+	ContextMessage      string `json:"context_message,omitempty"`
+	ContextState        string `json:"context_state,omitempty"`
+}
+
+// Context returns the context that caused the logbook entry
+func (e LogbookEntry) Context() Context {
+	return Context{
+		ID:     e.ContextID,
+		UserID: e.ContextUserID,
+	}
+}
@@ -0,0 +1,235 @@
+package hago
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// defaultTimeout is the REST request and WebSocket command timeout used when
+// WithTimeout is not given
+const defaultTimeout = 30 * time.Second
+
+// Option configures a client created by NewClient or NewWSClient.
+// Transport related options (TLS, proxy, headers, timeout) apply to both
+// REST requests and WebSocket dialing; options that only make sense for
+// one kind of client are ignored by the other.
+type Option func(*options)
+
+// options collects the settings applied by Option values
+type options struct {
+	retryPolicy     *RetryPolicy
+	reconnectPolicy ReconnectPolicy
+
+	heartbeatInterval time.Duration
+	heartbeatTimeout  time.Duration
+	features          []string
+	dispatchPolicy    DispatchPolicy
+
+	httpClient   *http.Client
+	transport    http.RoundTripper
+	tlsConfig    *tls.Config
+	rootCAs      *x509.CertPool
+	certificates []tls.Certificate
+	proxy        func(*http.Request) (*url.URL, error)
+	header       http.Header
+	timeout      time.Duration
+}
+
+// newOptions applies opts on top of the defaults
+func newOptions(opts []Option) *options {
+	o := &options{
+		header:          make(http.Header),
+		reconnectPolicy: DefaultReconnectPolicy(),
+		dispatchPolicy:  DefaultDispatchPolicy(),
+
+		heartbeatInterval: defaultHeartbeatInterval,
+		heartbeatTimeout:  defaultHeartbeatTimeout,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithRetryPolicy enables retries of failed REST requests using the given policy.
+// Use DefaultRetryPolicy for sensible defaults.
+func WithRetryPolicy(policy *RetryPolicy) Option {
+	return func(o *options) {
+		o.retryPolicy = policy
+	}
+}
+
+// WithReconnectPolicy sets how the WebSocket client restores a lost connection.
+// See DefaultReconnectPolicy, ExponentialReconnect and NoReconnect.
+func WithReconnectPolicy(policy ReconnectPolicy) Option {
+	return func(o *options) {
+		o.reconnectPolicy = policy
+	}
+}
+
+// WithHeartbeat sets how often the WebSocket client pings Home Assistant and how
+// long it waits for the answer. A missed answer is treated as a lost connection.
+// An interval of zero disables the heartbeat; a zero timeout waits a full interval.
+func WithHeartbeat(interval, timeout time.Duration) Option {
+	return func(o *options) {
+		o.heartbeatInterval = interval
+		o.heartbeatTimeout = timeout
+	}
+}
+
+// WithSupportedFeatures asks Home Assistant to enable the given WebSocket
+// protocol features after authentication
+func WithSupportedFeatures(features ...string) Option {
+	return func(o *options) {
+		o.features = append(o.features, features...)
+	}
+}
+
+// WithCoalesceMessages lets Home Assistant batch several WebSocket messages
+// into one frame, which reduces overhead on busy instances
+func WithCoalesceMessages() Option {
+	return WithSupportedFeatures("coalesce_messages")
+}
+
+// WithDispatchPolicy sets how WebSocket messages are queued for handlers and
+// subscription channels and what happens when a consumer falls behind
+func WithDispatchPolicy(policy DispatchPolicy) Option {
+	return func(o *options) {
+		o.dispatchPolicy = policy
+	}
+}
+
+// WithHTTPClient makes the REST client use the given http.Client as is.
+// TLS, proxy, transport and timeout options are then ignored for REST requests.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) {
+		o.httpClient = client
+	}
+}
+
+// WithTransport sets the RoundTripper used for REST requests.
+// TLS and proxy options are ignored for REST requests when a transport is given.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(o *options) {
+		o.transport = transport
+	}
+}
+
+// WithTLSConfig sets the base TLS configuration for REST and WebSocket connections
+func WithTLSConfig(config *tls.Config) Option {
+	return func(o *options) {
+		o.tlsConfig = config
+	}
+}
+
+// WithRootCAs trusts the given certificate pool, e.g. for self-signed reverse proxies
+func WithRootCAs(pool *x509.CertPool) Option {
+	return func(o *options) {
+		o.rootCAs = pool
+	}
+}
+
+// WithClientCertificate presents the given certificate for mutual TLS
+func WithClientCertificate(cert tls.Certificate) Option {
+	return func(o *options) {
+		o.certificates = append(o.certificates, cert)
+	}
+}
+
+// WithProxy sets the proxy function, e.g. http.ProxyURL(u) or http.ProxyFromEnvironment
+func WithProxy(proxy func(*http.Request) (*url.URL, error)) Option {
+	return func(o *options) {
+		o.proxy = proxy
+	}
+}
+
+// WithHeader adds a header sent with every REST request and the WebSocket handshake
+func WithHeader(key, value string) Option {
+	return func(o *options) {
+		o.header.Add(key, value)
+	}
+}
+
+// WithUserAgent sets the User-Agent header
+func WithUserAgent(userAgent string) Option {
+	return func(o *options) {
+		o.header.Set("User-Agent", userAgent)
+	}
+}
+
+// WithTimeout sets the REST request timeout, the WebSocket handshake timeout and
+// how long WSClient.Call waits for an answer when its context has no deadline
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
+
+// buildTLSConfig merges the TLS related options, returning nil when none were given
+func (o *options) buildTLSConfig() *tls.Config {
+	if o.tlsConfig == nil && o.rootCAs == nil && len(o.certificates) == 0 {
+		return nil
+	}
+
+	config := &tls.Config{}
+	if o.tlsConfig != nil {
+		config = o.tlsConfig.Clone()
+	}
+	if o.rootCAs != nil {
+		config.RootCAs = o.rootCAs
+	}
+	if len(o.certificates) > 0 {
+		config.Certificates = append(config.Certificates, o.certificates...)
+	}
+
+	return config
+}
+
+// buildHTTPClient returns the http.Client used for REST requests
+func (o *options) buildHTTPClient() *http.Client {
+	if o.httpClient != nil {
+		return o.httpClient
+	}
+
+	timeout := o.timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+
+	transport := o.transport
+	if transport == nil {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		if config := o.buildTLSConfig(); config != nil {
+			t.TLSClientConfig = config
+		}
+		if o.proxy != nil {
+			t.Proxy = o.proxy
+		}
+		transport = t
+	}
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}
+}
+
+// buildDialer returns the dialer used for WebSocket connections
+func (o *options) buildDialer() *websocket.Dialer {
+	dialer := *websocket.DefaultDialer
+	if config := o.buildTLSConfig(); config != nil {
+		dialer.TLSClientConfig = config
+	}
+	if o.proxy != nil {
+		dialer.Proxy = o.proxy
+	}
+	if o.timeout > 0 {
+		dialer.HandshakeTimeout = o.timeout
+	}
+	return &dialer
+}
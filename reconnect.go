@@ -0,0 +1,69 @@
+package hago
+
+import (
+	"time"
+)
+
+// ReconnectPolicy decides whether and when a WSClient retries a lost connection.
+// Reconnecting always stops when Home Assistant rejects the access token,
+// whatever the policy says.
+type ReconnectPolicy interface {
+	// NextDelay returns how long to wait before the given reconnect attempt
+	// (starting at 1), or false to give up. elapsed is the time since the
+	// connection was lost.
+	NextDelay(attempt int, elapsed time.Duration) (time.Duration, bool)
+}
+
+// ReconnectPolicyFunc adapts a function to the ReconnectPolicy interface
+type ReconnectPolicyFunc func(attempt int, elapsed time.Duration) (time.Duration, bool)
+
+// NextDelay calls f(attempt, elapsed)
+func (f ReconnectPolicyFunc) NextDelay(attempt int, elapsed time.Duration) (time.Duration, bool) {
+	return f(attempt, elapsed)
+}
+
+// ExponentialReconnect retries with exponential backoff and jitter
+type ExponentialReconnect struct {
+	// InitialDelay is the delay before the first attempt
+	InitialDelay time.Duration
+	// MaxDelay caps the delay between attempts
+	MaxDelay time.Duration
+	// Multiplier grows the delay after every attempt
+	Multiplier float64
+	// Jitter randomizes each delay by up to this fraction (0 to 1)
+	Jitter float64
+	// MaxAttempts gives up after this many attempts; 0 retries forever
+	MaxAttempts int
+	// MaxElapsedTime gives up once the connection has been down this long; 0 means no limit
+	MaxElapsedTime time.Duration
+}
+
+// NextDelay implements ReconnectPolicy
+func (p *ExponentialReconnect) NextDelay(attempt int, elapsed time.Duration) (time.Duration, bool) {
+	if p.MaxAttempts > 0 && attempt > p.MaxAttempts {
+		return 0, false
+	}
+	if p.MaxElapsedTime > 0 && elapsed >= p.MaxElapsedTime {
+		return 0, false
+	}
+
+	return backoffDelay(attempt, p.InitialDelay, p.MaxDelay, p.Multiplier, p.Jitter), true
+}
+
+// DefaultReconnectPolicy returns the policy used when WithReconnectPolicy is not
+// given: retry forever, starting after one second and backing off up to a minute
+func DefaultReconnectPolicy() ReconnectPolicy {
+	return &ExponentialReconnect{
+		InitialDelay: time.Second,
+		MaxDelay:     time.Minute,
+		Multiplier:   2,
+		Jitter:       0.2,
+	}
+}
+
+// NoReconnect returns a policy that never reconnects
+func NoReconnect() ReconnectPolicy {
+	return ReconnectPolicyFunc(func(int, time.Duration) (time.Duration, bool) {
+		return 0, false
+	})
+}
@@ -0,0 +1,441 @@
+package hago
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// StringPtr returns a pointer to s, for the optional fields of registry updates
+func StringPtr(s string) *string {
+	return &s
+}
+
+// setOptional adds an optional update field to a registry command. nil leaves
+// the field unchanged and an empty string clears it.
+func setOptional(message map[string]interface{}, key string, value *string) {
+	if value == nil {
+		return
+	}
+	if *value == "" {
+		message[key] = nil
+		return
+	}
+	message[key] = *value
+}
+
+// setNonEmpty adds a field to a registry command unless it is empty
+func setNonEmpty(message map[string]interface{}, key, value string) {
+	if value != "" {
+		message[key] = value
+	}
+}
+
+// callRegistry sends a registry command and decodes its result into v
+func (c *WSClient) callRegistry(ctx context.Context, message map[string]interface{}, v interface{}) error {
+	result, err := c.Call(ctx, message)
+	if err != nil {
+		return err
+	}
+	if v == nil {
+		return nil
+	}
+	return json.Unmarshal(result, v)
+}
+
+// DeviceUpdate holds the device registry fields to change. nil fields are left
+// unchanged; a pointer to an empty string clears the field.
+type DeviceUpdate struct {
+	AreaID     *string
+	NameByUser *string
+	// DisabledBy is "user" to disable the device, or empty to enable it
+	DisabledBy *string
+	// Labels replaces the labels of the device when not nil
+	Labels []string
+}
+
+// ListDevices returns all devices of the device registry
+func (c *WSClient) ListDevices(ctx context.Context) ([]Device, error) {
+	var devices []Device
+	err := c.callRegistry(ctx, map[string]interface{}{
+		"type": "config/device_registry/list",
+	}, &devices)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list devices: %w", err)
+	}
+
+	return devices, nil
+}
+
+// UpdateDevice changes a device in the device registry and returns the updated device
+func (c *WSClient) UpdateDevice(ctx context.Context, deviceID string, update DeviceUpdate) (*Device, error) {
+	message := map[string]interface{}{
+		"type":      "config/device_registry/update",
+		"device_id": deviceID,
+	}
+	setOptional(message, "area_id", update.AreaID)
+	setOptional(message, "name_by_user", update.NameByUser)
+	setOptional(message, "disabled_by", update.DisabledBy)
+	if update.Labels != nil {
+		message["labels"] = update.Labels
+	}
+
+	var device Device
+	if err := c.callRegistry(ctx, message, &device); err != nil {
+		return nil, fmt.Errorf("failed to update device %s: %w", deviceID, err)
+	}
+
+	return &device, nil
+}
+
+// EntityUpdate holds the entity registry fields to change. nil fields are left
+// unchanged; a pointer to an empty string clears the field.
+type EntityUpdate struct {
+	Name *string
+	Icon *string
+	// AreaID overrides the area of the entity's device
+	AreaID      *string
+	DeviceClass *string
+	// NewEntityID renames the entity ID
+	NewEntityID *string
+	// DisabledBy is "user" to disable the entity, or empty to enable it
+	DisabledBy *string
+	// HiddenBy is "user" to hide the entity, or empty to show it
+	HiddenBy *string
+	// Labels replaces the labels of the entity when not nil
+	Labels []string
+	// Aliases replaces the voice assistant aliases of the entity when not nil
+	Aliases []string
+}
+
+// ListEntities returns all entries of the entity registry. Entities that are
+// not in the registry, e.g. those without a unique ID, are not included.
+func (c *WSClient) ListEntities(ctx context.Context) ([]Entity, error) {
+	var entities []Entity
+	err := c.callRegistry(ctx, map[string]interface{}{
+		"type": "config/entity_registry/list",
+	}, &entities)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list entities: %w", err)
+	}
+
+	return entities, nil
+}
+
+// GetEntity returns the entity registry entry of an entity, including the
+// fields that are not part of ListEntities such as aliases and capabilities
+func (c *WSClient) GetEntity(ctx context.Context, entityID string) (*Entity, error) {
+	var entity Entity
+	err := c.callRegistry(ctx, map[string]interface{}{
+		"type":      "config/entity_registry/get",
+		"entity_id": entityID,
+	}, &entity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get entity %s: %w", entityID, err)
+	}
+
+	return &entity, nil
+}
+
+// UpdateEntity changes an entity in the entity registry and returns the updated entry
+func (c *WSClient) UpdateEntity(ctx context.Context, entityID string, update EntityUpdate) (*Entity, error) {
+	message := map[string]interface{}{
+		"type":      "config/entity_registry/update",
+		"entity_id": entityID,
+	}
+	setOptional(message, "name", update.Name)
+	setOptional(message, "icon", update.Icon)
+	setOptional(message, "area_id", update.AreaID)
+	setOptional(message, "device_class", update.DeviceClass)
+	setOptional(message, "disabled_by", update.DisabledBy)
+	setOptional(message, "hidden_by", update.HiddenBy)
+	if update.NewEntityID != nil {
+		message["new_entity_id"] = *update.NewEntityID
+	}
+	if update.Labels != nil {
+		message["labels"] = update.Labels
+	}
+	if update.Aliases != nil {
+		message["aliases"] = update.Aliases
+	}
+
+	var result struct {
+		EntityEntry Entity `json:"entity_entry"`
+	}
+	if err := c.callRegistry(ctx, message, &result); err != nil {
+		return nil, fmt.Errorf("failed to update entity %s: %w", entityID, err)
+	}
+
+	return &result.EntityEntry, nil
+}
+
+// RemoveEntity removes an entity from the entity registry
+func (c *WSClient) RemoveEntity(ctx context.Context, entityID string) error {
+	err := c.callRegistry(ctx, map[string]interface{}{
+		"type":      "config/entity_registry/remove",
+		"entity_id": entityID,
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to remove entity %s: %w", entityID, err)
+	}
+
+	return nil
+}
+
+// AreaUpdate holds the area registry fields to change. nil fields are left
+// unchanged; a pointer to an empty string clears the field.
+type AreaUpdate struct {
+	Name                *string
+	FloorID             *string
+	Icon                *string
+	Picture             *string
+	TemperatureEntityID *string
+	HumidityEntityID    *string
+	// Aliases replaces the aliases of the area when not nil
+	Aliases []string
+	// Labels replaces the labels of the area when not nil
+	Labels []string
+}
+
+// ListAreas returns all areas of the area registry
+func (c *WSClient) ListAreas(ctx context.Context) ([]Area, error) {
+	var areas []Area
+	err := c.callRegistry(ctx, map[string]interface{}{
+		"type": "config/area_registry/list",
+	}, &areas)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list areas: %w", err)
+	}
+
+	return areas, nil
+}
+
+// CreateArea creates an area from the given fields; its ID is assigned by Home Assistant
+func (c *WSClient) CreateArea(ctx context.Context, area Area) (*Area, error) {
+	message := map[string]interface{}{
+		"type": "config/area_registry/create",
+		"name": area.Name,
+	}
+	setNonEmpty(message, "floor_id", area.FloorID)
+	setNonEmpty(message, "icon", area.Icon)
+	setNonEmpty(message, "picture", area.Picture)
+	setNonEmpty(message, "temperature_entity_id", area.TemperatureEntityID)
+	setNonEmpty(message, "humidity_entity_id", area.HumidityEntityID)
+	if area.Aliases != nil {
+		message["aliases"] = area.Aliases
+	}
+	if area.Labels != nil {
+		message["labels"] = area.Labels
+	}
+
+	var created Area
+	if err := c.callRegistry(ctx, message, &created); err != nil {
+		return nil, fmt.Errorf("failed to create area %s: %w", area.Name, err)
+	}
+
+	return &created, nil
+}
+
+// UpdateArea changes an area in the area registry and returns the updated area
+func (c *WSClient) UpdateArea(ctx context.Context, areaID string, update AreaUpdate) (*Area, error) {
+	message := map[string]interface{}{
+		"type":    "config/area_registry/update",
+		"area_id": areaID,
+	}
+	if update.Name != nil {
+		message["name"] = *update.Name
+	}
+	setOptional(message, "floor_id", update.FloorID)
+	setOptional(message, "icon", update.Icon)
+	setOptional(message, "picture", update.Picture)
+	setOptional(message, "temperature_entity_id", update.TemperatureEntityID)
+	setOptional(message, "humidity_entity_id", update.HumidityEntityID)
+	if update.Aliases != nil {
+		message["aliases"] = update.Aliases
+	}
+	if update.Labels != nil {
+		message["labels"] = update.Labels
+	}
+
+	var area Area
+	if err := c.callRegistry(ctx, message, &area); err != nil {
+		return nil, fmt.Errorf("failed to update area %s: %w", areaID, err)
+	}
+
+	return &area, nil
+}
+
+// DeleteArea removes an area from the area registry
+func (c *WSClient) DeleteArea(ctx context.Context, areaID string) error {
+	err := c.callRegistry(ctx, map[string]interface{}{
+		"type":    "config/area_registry/delete",
+		"area_id": areaID,
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete area %s: %w", areaID, err)
+	}
+
+	return nil
+}
+
+// FloorUpdate holds the floor registry fields to change. nil fields are left
+// unchanged; a pointer to an empty string clears the field.
+type FloorUpdate struct {
+	Name *string
+	Icon *string
+	// Level sets the level of the floor when not nil
+	Level *int
+	// ClearLevel removes the level of the floor
+	ClearLevel bool
+	// Aliases replaces the aliases of the floor when not nil
+	Aliases []string
+}
+
+// ListFloors returns all floors of the floor registry
+func (c *WSClient) ListFloors(ctx context.Context) ([]Floor, error) {
+	var floors []Floor
+	err := c.callRegistry(ctx, map[string]interface{}{
+		"type": "config/floor_registry/list",
+	}, &floors)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list floors: %w", err)
+	}
+
+	return floors, nil
+}
+
+// CreateFloor creates a floor from the given fields; its ID is assigned by Home Assistant
+func (c *WSClient) CreateFloor(ctx context.Context, floor Floor) (*Floor, error) {
+	message := map[string]interface{}{
+		"type": "config/floor_registry/create",
+		"name": floor.Name,
+	}
+	setNonEmpty(message, "icon", floor.Icon)
+	if floor.Level != nil {
+		message["level"] = *floor.Level
+	}
+	if floor.Aliases != nil {
+		message["aliases"] = floor.Aliases
+	}
+
+	var created Floor
+	if err := c.callRegistry(ctx, message, &created); err != nil {
+		return nil, fmt.Errorf("failed to create floor %s: %w", floor.Name, err)
+	}
+
+	return &created, nil
+}
+
+// UpdateFloor changes a floor in the floor registry and returns the updated floor
+func (c *WSClient) UpdateFloor(ctx context.Context, floorID string, update FloorUpdate) (*Floor, error) {
+	message := map[string]interface{}{
+		"type":     "config/floor_registry/update",
+		"floor_id": floorID,
+	}
+	if update.Name != nil {
+		message["name"] = *update.Name
+	}
+	setOptional(message, "icon", update.Icon)
+	if update.ClearLevel {
+		message["level"] = nil
+	} else if update.Level != nil {
+		message["level"] = *update.Level
+	}
+	if update.Aliases != nil {
+		message["aliases"] = update.Aliases
+	}
+
+	var floor Floor
+	if err := c.callRegistry(ctx, message, &floor); err != nil {
+		return nil, fmt.Errorf("failed to update floor %s: %w", floorID, err)
+	}
+
+	return &floor, nil
+}
+
+// DeleteFloor removes a floor from the floor registry
+func (c *WSClient) DeleteFloor(ctx context.Context, floorID string) error {
+	err := c.callRegistry(ctx, map[string]interface{}{
+		"type":     "config/floor_registry/delete",
+		"floor_id": floorID,
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete floor %s: %w", floorID, err)
+	}
+
+	return nil
+}
+
+// LabelUpdate holds the label registry fields to change. nil fields are left
+// unchanged; a pointer to an empty string clears the field.
+type LabelUpdate struct {
+	Name        *string
+	Icon        *string
+	Color       *string
+	Description *string
+}
+
+// ListLabels returns all labels of the label registry
+func (c *WSClient) ListLabels(ctx context.Context) ([]Label, error) {
+	var labels []Label
+	err := c.callRegistry(ctx, map[string]interface{}{
+		"type": "config/label_registry/list",
+	}, &labels)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list labels: %w", err)
+	}
+
+	return labels, nil
+}
+
+// CreateLabel creates a label from the given fields; its ID is assigned by Home Assistant
+func (c *WSClient) CreateLabel(ctx context.Context, label Label) (*Label, error) {
+	message := map[string]interface{}{
+		"type": "config/label_registry/create",
+		"name": label.Name,
+	}
+	setNonEmpty(message, "icon", label.Icon)
+	setNonEmpty(message, "color", label.Color)
+	setNonEmpty(message, "description", label.Description)
+
+	var created Label
+	if err := c.callRegistry(ctx, message, &created); err != nil {
+		return nil, fmt.Errorf("failed to create label %s: %w", label.Name, err)
+	}
+
+	return &created, nil
+}
+
+// UpdateLabel changes a label in the label registry and returns the updated label
+func (c *WSClient) UpdateLabel(ctx context.Context, labelID string, update LabelUpdate) (*Label, error) {
+	message := map[string]interface{}{
+		"type":     "config/label_registry/update",
+		"label_id": labelID,
+	}
+	if update.Name != nil {
+		message["name"] = *update.Name
+	}
+	setOptional(message, "icon", update.Icon)
+	setOptional(message, "color", update.Color)
+	setOptional(message, "description", update.Description)
+
+	var label Label
+	if err := c.callRegistry(ctx, message, &label); err != nil {
+		return nil, fmt.Errorf("failed to update label %s: %w", labelID, err)
+	}
+
+	return &label, nil
+}
+
+// DeleteLabel removes a label from the label registry
+func (c *WSClient) DeleteLabel(ctx context.Context, labelID string) error {
+	err := c.callRegistry(ctx, map[string]interface{}{
+		"type":     "config/label_registry/delete",
+		"label_id": labelID,
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete label %s: %w", labelID, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,173 @@
+package hago
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how the REST client retries failed requests
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between attempts. When a Retry-After header
+	// asks for a longer delay, the response is returned without retrying.
+	MaxBackoff time.Duration
+	// Multiplier grows the delay after every attempt
+	Multiplier float64
+	// Jitter randomizes each delay by up to this fraction (0 to 1)
+	Jitter float64
+	// RetryableStatusCodes lists the response codes that trigger a retry
+	RetryableStatusCodes []int
+	// RetryableMethods lists the HTTP methods that may be replayed.
+	// Requests marked with MarkIdempotent are retried regardless of method.
+	RetryableMethods []string
+}
+
+// DefaultRetryPolicy returns a policy suited for riding out Home Assistant
+// restarts and proxy hiccups. POST requests are not retried unless marked idempotent.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    4,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     10 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+		RetryableStatusCodes: []int{
+			http.StatusTooManyRequests,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+		RetryableMethods: []string{
+			http.MethodGet,
+			http.MethodHead,
+			http.MethodPut,
+			http.MethodDelete,
+			http.MethodOptions,
+		},
+	}
+}
+
+type idempotentKey struct{}
+
+// MarkIdempotent returns a context that flags the request made with it as
+// safe to replay, allowing retries for methods such as POST
+func MarkIdempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentKey{}, true)
+}
+
+// isIdempotent reports whether ctx was marked with MarkIdempotent
+func isIdempotent(ctx context.Context) bool {
+	marked, _ := ctx.Value(idempotentKey{}).(bool)
+	return marked
+}
+
+// attempts returns how many times a request may be sent under the policy
+func (p *RetryPolicy) attempts(ctx context.Context, method string) int {
+	if p == nil || p.MaxAttempts <= 1 {
+		return 1
+	}
+
+	if isIdempotent(ctx) {
+		return p.MaxAttempts
+	}
+	for _, m := range p.RetryableMethods {
+		if m == method {
+			return p.MaxAttempts
+		}
+	}
+
+	return 1
+}
+
+// retryableStatus reports whether a response with the given code should be retried
+func (p *RetryPolicy) retryableStatus(code int) bool {
+	for _, c := range p.RetryableStatusCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the delay to wait after the given attempt (starting at 1)
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	return backoffDelay(attempt, p.InitialBackoff, p.MaxBackoff, p.Multiplier, p.Jitter)
+}
+
+// retryWait returns the delay before retrying a response with a retryable
+// status, honoring Retry-After. It reports false when the server asks for a
+// longer delay than MaxBackoff allows.
+func (p *RetryPolicy) retryWait(attempt int, resp *http.Response) (time.Duration, bool) {
+	wait := p.backoff(attempt)
+
+	d, ok := retryAfter(resp)
+	if !ok || d <= wait {
+		return wait, true
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		return 0, false
+	}
+	return d, true
+}
+
+// backoffDelay computes an exponential delay with jitter, capped at max
+func backoffDelay(attempt int, initial, max time.Duration, multiplier, jitter float64) time.Duration {
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	delay := float64(initial) * math.Pow(multiplier, float64(attempt-1))
+	if max > 0 && delay > float64(max) {
+		delay = float64(max)
+	}
+
+	if jitter > 0 {
+		if jitter > 1 {
+			jitter = 1
+		}
+		delay += delay * jitter * (2*rand.Float64() - 1)
+	}
+
+	return time.Duration(delay)
+}
+
+// retryAfter parses the Retry-After header, given either in seconds or as an HTTP date
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if d := time.Until(date); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+
+	return 0, false
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
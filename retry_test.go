@@ -0,0 +1,139 @@
+package hago
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	tests := []struct {
+		name       string
+		attempt    int
+		initial    time.Duration
+		max        time.Duration
+		multiplier float64
+		jitter     float64
+		min        time.Duration
+		want       time.Duration
+	}{
+		{name: "first attempt", attempt: 1, initial: time.Second, max: time.Minute, multiplier: 2, min: time.Second, want: time.Second},
+		{name: "exponential growth", attempt: 4, initial: time.Second, max: time.Minute, multiplier: 2, min: 8 * time.Second, want: 8 * time.Second},
+		{name: "capped at max", attempt: 10, initial: time.Second, max: 10 * time.Second, multiplier: 2, min: 10 * time.Second, want: 10 * time.Second},
+		{name: "no max", attempt: 10, initial: time.Second, multiplier: 2, min: 512 * time.Second, want: 512 * time.Second},
+		{name: "multiplier below one", attempt: 5, initial: time.Second, max: time.Minute, multiplier: 0.5, min: time.Second, want: time.Second},
+		{name: "jitter", attempt: 2, initial: time.Second, max: time.Minute, multiplier: 2, jitter: 0.5, min: time.Second, want: 3 * time.Second},
+		{name: "jitter above one", attempt: 1, initial: time.Second, max: time.Minute, multiplier: 2, jitter: 3, min: 0, want: 2 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Jitter is random, so sample enough delays to cover its range
+			for i := 0; i < 100; i++ {
+				got := backoffDelay(tt.attempt, tt.initial, tt.max, tt.multiplier, tt.jitter)
+				if got < tt.min || got > tt.want {
+					t.Fatalf("backoffDelay() = %v, want between %v and %v", got, tt.min, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		min    time.Duration
+		want   time.Duration
+		wantOK bool
+	}{
+		{name: "missing"},
+		{name: "seconds", header: "120", min: 2 * time.Minute, want: 2 * time.Minute, wantOK: true},
+		{name: "zero seconds", header: "0", wantOK: true},
+		{name: "negative seconds", header: "-5"},
+		{name: "future date", header: time.Now().Add(time.Hour).UTC().Format(http.TimeFormat), min: 58 * time.Minute, want: time.Hour, wantOK: true},
+		{name: "past date", header: "Wed, 21 Oct 2015 07:28:00 GMT", wantOK: true},
+		{name: "invalid", header: "soon"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{}}
+			if tt.header != "" {
+				resp.Header.Set("Retry-After", tt.header)
+			}
+
+			got, ok := retryAfter(resp)
+			if ok != tt.wantOK || got < tt.min || got > tt.want {
+				t.Errorf("retryAfter(%q) = %v, %v, want between %v and %v, %v", tt.header, got, ok, tt.min, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyAttempts(t *testing.T) {
+	policy := DefaultRetryPolicy()
+
+	tests := []struct {
+		name   string
+		policy *RetryPolicy
+		ctx    context.Context
+		method string
+		want   int
+	}{
+		{name: "nil policy", ctx: context.Background(), method: http.MethodGet, want: 1},
+		{name: "single attempt", policy: &RetryPolicy{MaxAttempts: 1, RetryableMethods: []string{http.MethodGet}}, ctx: context.Background(), method: http.MethodGet, want: 1},
+		{name: "retryable method", policy: policy, ctx: context.Background(), method: http.MethodGet, want: policy.MaxAttempts},
+		{name: "non-retryable method", policy: policy, ctx: context.Background(), method: http.MethodPost, want: 1},
+		{name: "idempotent POST", policy: policy, ctx: MarkIdempotent(context.Background()), method: http.MethodPost, want: policy.MaxAttempts},
+		{name: "idempotent with single attempt", policy: &RetryPolicy{MaxAttempts: 1}, ctx: MarkIdempotent(context.Background()), method: http.MethodPost, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.attempts(tt.ctx, tt.method); got != tt.want {
+				t.Errorf("attempts(%s) = %d, want %d", tt.method, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyRetryWait(t *testing.T) {
+	policy := &RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 10 * time.Second, Multiplier: 2}
+
+	tests := []struct {
+		name       string
+		policy     *RetryPolicy
+		retryAfter string
+		want       time.Duration
+		wantOK     bool
+	}{
+		{name: "no header", policy: policy, want: 2 * time.Second, wantOK: true},
+		{name: "shorter than backoff", policy: policy, retryAfter: "1", want: 2 * time.Second, wantOK: true},
+		{name: "longer than backoff", policy: policy, retryAfter: "5", want: 5 * time.Second, wantOK: true},
+		{name: "equal to max", policy: policy, retryAfter: "10", want: 10 * time.Second, wantOK: true},
+		{name: "longer than max", policy: policy, retryAfter: "3600"},
+		{
+			name:       "no max",
+			policy:     &RetryPolicy{InitialBackoff: time.Second, Multiplier: 2},
+			retryAfter: "3600",
+			want:       time.Hour,
+			wantOK:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{}}
+			if tt.retryAfter != "" {
+				resp.Header.Set("Retry-After", tt.retryAfter)
+			}
+
+			got, ok := tt.policy.retryWait(2, resp)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("retryWait() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
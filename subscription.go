@@ -0,0 +1,270 @@
+package hago
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+)
+
+// Subscription is an active server-side subscription created on a WSClient.
+// It receives only the event messages that belong to it. Subscriptions are
+// replayed automatically after the client reconnects.
+type Subscription struct {
+	client  *WSClient
+	message map[string]interface{}
+	handle  func(msg *wsMessage)
+	onClose func()
+	// onResubscribe runs before the subscription is replayed on a new connection
+	onResubscribe func()
+
+	id       atomic.Int64
+	done     chan struct{}
+	doneOnce sync.Once
+
+	// mu serializes event delivery with closing
+	mu      sync.Mutex
+	closed  bool
+	dropped atomic.Uint64
+
+	// queue runs consumer callbacks off the reader goroutine
+	queue     *dispatchQueue
+	queueOnce sync.Once
+}
+
+// newSubscription prepares a subscription for the given command.
+// handle receives the subscription's event messages on the reader goroutine.
+func (c *WSClient) newSubscription(message map[string]interface{}, handle func(msg *wsMessage)) *Subscription {
+	return &Subscription{
+		client:  c,
+		message: message,
+		handle:  handle,
+		done:    make(chan struct{}),
+	}
+}
+
+// ID returns the ID Home Assistant uses for the subscription.
+// The ID changes when the subscription is replayed after a reconnect.
+func (s *Subscription) ID() int64 {
+	return s.id.Load()
+}
+
+// Done returns a channel that is closed once the subscription has ended, either
+// through Unsubscribe, because the client was closed or because it could not
+// be replayed after a reconnect
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// enqueue runs fn on the subscription's own goroutine, in order, so that
+// consumer callbacks never block the reader
+func (s *Subscription) enqueue(fn func()) {
+	s.queueOnce.Do(func() {
+		s.queue = s.client.newDispatchQueue(s.done, &s.dropped)
+	})
+	s.queue.push(fn)
+}
+
+// Dropped returns the number of events discarded because the consumer fell
+// behind, see OverflowPolicy
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Unsubscribe ends the subscription on the server and releases its resources.
+// It is safe to call more than once.
+func (s *Subscription) Unsubscribe(ctx context.Context) error {
+	id := s.ID()
+	s.client.removeSubscription(id)
+	if !s.close() {
+		return nil
+	}
+
+	_, err := s.client.Call(ctx, map[string]interface{}{
+		"type":         "unsubscribe_events",
+		"subscription": id,
+	})
+	// The server drops subscriptions together with the connection
+	if errors.Is(err, ErrNotConnected) || errors.Is(err, ErrClientClosed) {
+		return nil
+	}
+	return err
+}
+
+// close marks the subscription as ended and runs its close hook.
+// It reports whether this call was the one that closed it.
+func (s *Subscription) close() bool {
+	first := false
+	s.doneOnce.Do(func() {
+		// Closing done first unblocks a delivery waiting on a full channel
+		close(s.done)
+		first = true
+	})
+	if !first {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	if s.onClose != nil {
+		s.onClose()
+	}
+	return true
+}
+
+// subscribe sends the subscription command of sub and waits for it to be accepted
+func (c *WSClient) subscribe(ctx context.Context, sub *Subscription) error {
+	// Register before sending so that no early event is missed
+	id := c.nextID()
+	sub.id.Store(id)
+	c.mu.Lock()
+	c.subscriptions[id] = sub
+	c.mu.Unlock()
+
+	if _, err := c.call(ctx, id, sub.message); err != nil {
+		c.removeSubscription(id)
+		sub.close()
+		return err
+	}
+
+	return nil
+}
+
+// removeSubscription stops routing events for the given subscription ID
+func (c *WSClient) removeSubscription(id int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.subscriptions, id)
+}
+
+// closeSubscriptions ends every active subscription, e.g. when the client is closed
+func (c *WSClient) closeSubscriptions() {
+	c.mu.Lock()
+	subs := c.subscriptions
+	c.subscriptions = make(map[int64]*Subscription)
+	c.mu.Unlock()
+
+	for _, sub := range subs {
+		sub.close()
+	}
+}
+
+// resubscribe replays every active subscription on a new connection. Home Assistant
+// assigns new IDs, so subscriptions are registered again under fresh IDs.
+// Reconnect handlers run once all subscriptions have been replayed.
+func (c *WSClient) resubscribe() {
+	c.mu.Lock()
+	subs := c.subscriptions
+	c.subscriptions = make(map[int64]*Subscription)
+	handlers := append([]func(){}, c.onReconnect...)
+	c.mu.Unlock()
+
+	for oldID, sub := range subs {
+		select {
+		case <-sub.done:
+			continue
+		default:
+		}
+
+		if sub.onResubscribe != nil {
+			sub.onResubscribe()
+		}
+
+		// A subscription that cannot be replayed is closed by subscribe
+		if err := c.subscribe(context.Background(), sub); err != nil {
+			log.Printf("Failed to resubscribe subscription %d (%v): %v", oldID, sub.message["type"], err)
+			continue
+		}
+
+		// Unsubscribe may have raced with the replay
+		select {
+		case <-sub.done:
+			c.removeSubscription(sub.ID())
+			c.Call(context.Background(), map[string]interface{}{
+				"type":         "unsubscribe_events",
+				"subscription": sub.ID(),
+			})
+		default:
+		}
+	}
+
+	for _, handler := range handlers {
+		handler()
+	}
+}
+
+// OnReconnect registers a handler called after the client reconnected and
+// replayed its subscriptions. Events that happened while the connection was
+// down are lost, so consumers should use it to resync their state.
+func (c *WSClient) OnReconnect(handler func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.onReconnect = append(c.onReconnect, handler)
+}
+
+// EventSubscription is a subscription to Home Assistant events
+type EventSubscription struct {
+	*Subscription
+	events chan Event
+}
+
+// Events returns the channel the subscribed events are delivered on.
+// The channel is closed when the subscription ends. Once its buffer is full
+// the dispatch policy's OverflowPolicy applies, which by default drops the
+// oldest event; see Dropped.
+func (s *EventSubscription) Events() <-chan Event {
+	return s.events
+}
+
+// deliver hands an event to the consumer of the subscription unless it has
+// ended, following the client's overflow policy
+func deliver[T any](s *Subscription, events chan T, event T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	queued, dropped := offer(events, event, s.client.dispatchPolicy.Overflow, s.done)
+	s.dropped.Add(uint64(dropped))
+	s.client.countDelivery(queued, dropped)
+}
+
+// SubscribeEvents subscribes to Home Assistant events of the given type,
+// or to all events when eventType is empty
+func (c *WSClient) SubscribeEvents(ctx context.Context, eventType string) (*EventSubscription, error) {
+	message := map[string]interface{}{
+		"type": "subscribe_events",
+	}
+	if eventType != "" {
+		message["event_type"] = eventType
+	}
+
+	sub := &EventSubscription{
+		events: make(chan Event, c.dispatchPolicy.QueueSize),
+	}
+	sub.Subscription = c.newSubscription(message, func(msg *wsMessage) {
+		var event Event
+		if err := json.Unmarshal(msg.Event, &event); err != nil {
+			log.Printf("Error decoding event: %v", err)
+			return
+		}
+		deliver(sub.Subscription, sub.events, event)
+	})
+	sub.onClose = func() {
+		close(sub.events)
+	}
+
+	if err := c.subscribe(ctx, sub.Subscription); err != nil {
+		return nil, err
+	}
+
+	return sub, nil
+}
@@ -0,0 +1,129 @@
+package hago
+
+import (
+	"strings"
+)
+
+// Target selects what a service call acts on. Entities, devices, areas, floors
+// and labels can be combined; Home Assistant resolves them to the union of
+// matching entities. The zero value targets nothing, leaving it to the
+// service data.
+type Target struct {
+	EntityIDs []string
+	DeviceIDs []string
+	AreaIDs   []string
+	FloorIDs  []string
+	LabelIDs  []string
+}
+
+// TargetEntities returns a target selecting the given entities
+func TargetEntities(entityIDs ...string) Target {
+	return Target{}.Entities(entityIDs...)
+}
+
+// TargetDevices returns a target selecting all entities of the given devices
+func TargetDevices(deviceIDs ...string) Target {
+	return Target{}.Devices(deviceIDs...)
+}
+
+// TargetAreas returns a target selecting all entities in the given areas
+func TargetAreas(areaIDs ...string) Target {
+	return Target{}.Areas(areaIDs...)
+}
+
+// TargetFloors returns a target selecting all entities on the given floors
+func TargetFloors(floorIDs ...string) Target {
+	return Target{}.Floors(floorIDs...)
+}
+
+// TargetLabels returns a target selecting all entities with the given labels
+func TargetLabels(labelIDs ...string) Target {
+	return Target{}.Labels(labelIDs...)
+}
+
+// Entities returns a copy of the target that also selects the given entities
+func (t Target) Entities(entityIDs ...string) Target {
+	t.EntityIDs = append(append([]string{}, t.EntityIDs...), entityIDs...)
+	return t
+}
+
+// Devices returns a copy of the target that also selects the given devices
+func (t Target) Devices(deviceIDs ...string) Target {
+	t.DeviceIDs = append(append([]string{}, t.DeviceIDs...), deviceIDs...)
+	return t
+}
+
+// Areas returns a copy of the target that also selects the given areas
+func (t Target) Areas(areaIDs ...string) Target {
+	t.AreaIDs = append(append([]string{}, t.AreaIDs...), areaIDs...)
+	return t
+}
+
+// Floors returns a copy of the target that also selects the given floors
+func (t Target) Floors(floorIDs ...string) Target {
+	t.FloorIDs = append(append([]string{}, t.FloorIDs...), floorIDs...)
+	return t
+}
+
+// Labels returns a copy of the target that also selects the given labels
+func (t Target) Labels(labelIDs ...string) Target {
+	t.LabelIDs = append(append([]string{}, t.LabelIDs...), labelIDs...)
+	return t
+}
+
+// IsEmpty reports whether the target selects nothing
+func (t Target) IsEmpty() bool {
+	return len(t.EntityIDs) == 0 && len(t.DeviceIDs) == 0 && len(t.AreaIDs) == 0 &&
+		len(t.FloorIDs) == 0 && len(t.LabelIDs) == 0
+}
+
+// fields returns the target in the format of Home Assistant service calls
+func (t Target) fields() map[string]interface{} {
+	fields := make(map[string]interface{})
+	for key, ids := range map[string][]string{
+		"entity_id": t.EntityIDs,
+		"device_id": t.DeviceIDs,
+		"area_id":   t.AreaIDs,
+		"floor_id":  t.FloorIDs,
+		"label_id":  t.LabelIDs,
+	} {
+		if len(ids) > 0 {
+			fields[key] = ids
+		}
+	}
+	return fields
+}
+
+// withDomain prefixes entity IDs given without their domain, so that helpers
+// accept both "kitchen" and "light.kitchen"
+func (t Target) withDomain(domain string) Target {
+	if len(t.EntityIDs) == 0 {
+		return t
+	}
+
+	entityIDs := make([]string, len(t.EntityIDs))
+	for i, entityID := range t.EntityIDs {
+		if !strings.HasPrefix(entityID, domain+".") {
+			entityID = domain + "." + entityID
+		}
+		entityIDs[i] = entityID
+	}
+	t.EntityIDs = entityIDs
+	return t
+}
+
+// serviceBody merges the target into the service data as expected by the REST API
+func serviceBody(target Target, data map[string]interface{}) map[string]interface{} {
+	if target.IsEmpty() {
+		return data
+	}
+
+	body := make(map[string]interface{}, len(data)+5)
+	for key, value := range data {
+		body[key] = value
+	}
+	for key, value := range target.fields() {
+		body[key] = value
+	}
+	return body
+}
@@ -0,0 +1,114 @@
+package hago
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// TemplateRequest describes a template rendered by WSClient.SubscribeTemplate
+type TemplateRequest struct {
+	// Template is the Jinja template to render
+	Template string
+	// Variables are made available to the template
+	Variables map[string]interface{}
+	// Timeout aborts the first render if it takes longer than this
+	Timeout time.Duration
+	// Strict raises errors for undefined variables
+	Strict bool
+	// ReportErrors delivers render errors and warnings as updates instead of
+	// only logging them on the server
+	ReportErrors bool
+}
+
+// TemplateUpdate is a rendering result pushed by WSClient.SubscribeTemplate
+type TemplateUpdate struct {
+	// Result is the rendered value; Home Assistant converts it to a native
+	// type (number, list, ...) when possible
+	Result interface{} `json:"result"`
+	// Listeners describes which changes cause the template to re-render
+	Listeners *TemplateListeners `json:"listeners,omitempty"`
+	// Error is set instead of Result when rendering failed
+	Error string `json:"error,omitempty"`
+	// Level is "ERROR" or "WARNING" when Error is set
+	Level string `json:"level,omitempty"`
+}
+
+// TemplateListeners describes what a rendered template is listening to
+type TemplateListeners struct {
+	All      bool     `json:"all"`
+	Domains  []string `json:"domains"`
+	Entities []string `json:"entities"`
+	Time     bool     `json:"time"`
+}
+
+// RenderTemplate renders a template once on the server and returns the result
+func (a *API) RenderTemplate(ctx context.Context, template string, variables map[string]interface{}) (string, error) {
+	body := map[string]interface{}{
+		"template": template,
+	}
+	if variables != nil {
+		body["variables"] = variables
+	}
+
+	// Rendering has no side effects, so it is safe to retry
+	resp, err := a.client.Post(MarkIdempotent(ctx), "/api/template", body)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp, http.StatusOK); err != nil {
+		return "", fmt.Errorf("failed to render template: %w", err)
+	}
+
+	result, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	return string(result), nil
+}
+
+// SubscribeTemplate renders a template on the server and calls handler with the
+// result, and again every time an entity referenced by the template changes.
+// Handler calls are queued per subscription following the dispatch policy.
+func (c *WSClient) SubscribeTemplate(ctx context.Context, req TemplateRequest, handler func(TemplateUpdate)) (*Subscription, error) {
+	message := map[string]interface{}{
+		"type":     "render_template",
+		"template": req.Template,
+	}
+	if req.Variables != nil {
+		message["variables"] = req.Variables
+	}
+	if req.Timeout > 0 {
+		message["timeout"] = req.Timeout.Seconds()
+	}
+	if req.Strict {
+		message["strict"] = true
+	}
+	if req.ReportErrors {
+		message["report_errors"] = true
+	}
+
+	var sub *Subscription
+	sub = c.newSubscription(message, func(msg *wsMessage) {
+		var update TemplateUpdate
+		if err := json.Unmarshal(msg.Event, &update); err != nil {
+			update = TemplateUpdate{Error: err.Error(), Level: "ERROR"}
+		}
+		sub.enqueue(func() {
+			handler(update)
+		})
+	})
+
+	// An invalid template is rejected in the command result
+	if err := c.subscribe(ctx, sub); err != nil {
+		return nil, fmt.Errorf("failed to render template: %w", err)
+	}
+
+	return sub, nil
+}
@@ -0,0 +1,534 @@
+package hago
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Registry events fired by Home Assistant when a registry changes
+const (
+	EventEntityRegistryUpdated = "entity_registry_updated"
+	EventDeviceRegistryUpdated = "device_registry_updated"
+	EventAreaRegistryUpdated   = "area_registry_updated"
+	EventFloorRegistryUpdated  = "floor_registry_updated"
+)
+
+// EntityInfo joins everything known about an entity. Fields are nil when the
+// entity has no registry entry, is not assigned to a device, area or floor,
+// or currently has no state.
+type EntityInfo struct {
+	EntityID string
+	State    *State
+	Entity   *Entity
+	Device   *Device
+	Area     *Area
+	Floor    *Floor
+}
+
+// Topology joins states with the entity, device, area and floor registries so
+// that questions like "which floor is this sensor on" can be answered locally.
+// An entity's own area takes precedence over the area of its device.
+// It is safe for concurrent use.
+type Topology struct {
+	client *WSClient
+
+	mu       sync.RWMutex
+	states   map[string]State
+	entities map[string]Entity
+	devices  map[string]Device
+	areas    map[string]Area
+	floors   map[string]Floor
+
+	// Indexes, rebuilt whenever a registry is reloaded
+	entityArea       map[string]string
+	entitiesByDevice map[string][]string
+	entitiesByArea   map[string][]string
+	devicesByArea    map[string][]string
+	areasByFloor     map[string][]string
+
+	// Set by Watch; watchMu serializes Watch and Close
+	watchMu  sync.Mutex
+	mirror   *EntityMirror
+	subs     []*EventSubscription
+	hookOnce sync.Once
+}
+
+// LoadTopology loads states and registries and joins them. The result is a
+// snapshot; call Watch to keep it up to date.
+func (c *WSClient) LoadTopology(ctx context.Context) (*Topology, error) {
+	t := &Topology{client: c}
+	if err := t.Refresh(ctx); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Refresh reloads states and all registries
+func (t *Topology) Refresh(ctx context.Context) error {
+	var states []State
+	result, err := t.client.Call(ctx, map[string]interface{}{
+		"type": "get_states",
+	})
+	if err == nil {
+		err = json.Unmarshal(result, &states)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get states: %w", err)
+	}
+
+	entities, err := t.client.ListEntities(ctx)
+	if err != nil {
+		return err
+	}
+	devices, err := t.client.ListDevices(ctx)
+	if err != nil {
+		return err
+	}
+	areas, err := t.client.ListAreas(ctx)
+	if err != nil {
+		return err
+	}
+	floors, err := t.client.ListFloors(ctx)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.states = make(map[string]State, len(states))
+	for _, state := range states {
+		t.states[state.EntityID] = state
+	}
+	t.setEntities(entities)
+	t.setDevices(devices)
+	t.setAreas(areas)
+	t.setFloors(floors)
+	t.reindex()
+
+	return nil
+}
+
+// refreshRegistry reloads the registry named by a *_registry_updated event
+func (t *Topology) refreshRegistry(ctx context.Context, eventType string) error {
+	var apply func()
+	switch eventType {
+	case EventEntityRegistryUpdated:
+		entities, err := t.client.ListEntities(ctx)
+		if err != nil {
+			return err
+		}
+		apply = func() { t.setEntities(entities) }
+	case EventDeviceRegistryUpdated:
+		devices, err := t.client.ListDevices(ctx)
+		if err != nil {
+			return err
+		}
+		apply = func() { t.setDevices(devices) }
+	case EventAreaRegistryUpdated:
+		areas, err := t.client.ListAreas(ctx)
+		if err != nil {
+			return err
+		}
+		apply = func() { t.setAreas(areas) }
+	case EventFloorRegistryUpdated:
+		floors, err := t.client.ListFloors(ctx)
+		if err != nil {
+			return err
+		}
+		apply = func() { t.setFloors(floors) }
+	default:
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	apply()
+	t.reindex()
+	return nil
+}
+
+// setEntities replaces the entity registry. t.mu must be held.
+func (t *Topology) setEntities(entities []Entity) {
+	t.entities = make(map[string]Entity, len(entities))
+	for _, entity := range entities {
+		t.entities[entity.EntityID] = entity
+	}
+}
+
+// setDevices replaces the device registry. t.mu must be held.
+func (t *Topology) setDevices(devices []Device) {
+	t.devices = make(map[string]Device, len(devices))
+	for _, device := range devices {
+		t.devices[device.ID] = device
+	}
+}
+
+// setAreas replaces the area registry. t.mu must be held.
+func (t *Topology) setAreas(areas []Area) {
+	t.areas = make(map[string]Area, len(areas))
+	for _, area := range areas {
+		t.areas[area.ID] = area
+	}
+}
+
+// setFloors replaces the floor registry. t.mu must be held.
+func (t *Topology) setFloors(floors []Floor) {
+	t.floors = make(map[string]Floor, len(floors))
+	for _, floor := range floors {
+		t.floors[floor.ID] = floor
+	}
+}
+
+// reindex rebuilds the lookup indexes. t.mu must be held.
+func (t *Topology) reindex() {
+	t.entityArea = make(map[string]string)
+	t.entitiesByDevice = make(map[string][]string)
+	t.entitiesByArea = make(map[string][]string)
+	t.devicesByArea = make(map[string][]string)
+	t.areasByFloor = make(map[string][]string)
+
+	for _, entity := range t.entities {
+		if entity.DeviceID != "" {
+			t.entitiesByDevice[entity.DeviceID] = append(t.entitiesByDevice[entity.DeviceID], entity.EntityID)
+		}
+
+		// The entity's own area overrides the area of its device
+		areaID := entity.AreaID
+		if areaID == "" {
+			areaID = t.devices[entity.DeviceID].AreaID
+		}
+		if areaID != "" {
+			t.entityArea[entity.EntityID] = areaID
+			t.entitiesByArea[areaID] = append(t.entitiesByArea[areaID], entity.EntityID)
+		}
+	}
+	for _, device := range t.devices {
+		if device.AreaID != "" {
+			t.devicesByArea[device.AreaID] = append(t.devicesByArea[device.AreaID], device.ID)
+		}
+	}
+	for _, area := range t.areas {
+		if area.FloorID != "" {
+			t.areasByFloor[area.FloorID] = append(t.areasByFloor[area.FloorID], area.ID)
+		}
+	}
+
+	for _, index := range []map[string][]string{t.entitiesByDevice, t.entitiesByArea, t.devicesByArea, t.areasByFloor} {
+		for _, ids := range index {
+			sort.Strings(ids)
+		}
+	}
+}
+
+// Entity returns the joined information of an entity
+func (t *Topology) Entity(entityID string) (EntityInfo, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.entityInfo(entityID)
+}
+
+// entityInfo joins the information of an entity. t.mu must be held.
+func (t *Topology) entityInfo(entityID string) (EntityInfo, bool) {
+	info := EntityInfo{EntityID: entityID}
+
+	if state, ok := t.state(entityID); ok {
+		info.State = &state
+	}
+	if entity, ok := t.entities[entityID]; ok {
+		info.Entity = &entity
+		if device, ok := t.devices[entity.DeviceID]; ok {
+			info.Device = &device
+		}
+	}
+	if area, ok := t.areas[t.entityArea[entityID]]; ok {
+		info.Area = &area
+		if floor, ok := t.floors[area.FloorID]; ok {
+			info.Floor = &floor
+		}
+	}
+
+	return info, info.State != nil || info.Entity != nil
+}
+
+// state returns the current state of an entity, live when watching. t.mu must be held.
+func (t *Topology) state(entityID string) (State, bool) {
+	if t.mirror != nil {
+		return t.mirror.Get(entityID)
+	}
+	state, ok := t.states[entityID]
+	return state, ok
+}
+
+// entityInfos joins the information of the given entities. t.mu must be held.
+func (t *Topology) entityInfos(entityIDs []string) []EntityInfo {
+	infos := make([]EntityInfo, 0, len(entityIDs))
+	for _, entityID := range entityIDs {
+		info, _ := t.entityInfo(entityID)
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// Entities returns the joined information of every entity that has a state
+// or a registry entry, sorted by entity ID
+func (t *Topology) Entities() []EntityInfo {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	seen := make(map[string]bool, len(t.entities))
+	var entityIDs []string
+	for entityID := range t.entities {
+		seen[entityID] = true
+		entityIDs = append(entityIDs, entityID)
+	}
+	states := t.states
+	if t.mirror != nil {
+		states = make(map[string]State)
+		for _, state := range t.mirror.All() {
+			states[state.EntityID] = state
+		}
+	}
+	for entityID := range states {
+		if !seen[entityID] {
+			entityIDs = append(entityIDs, entityID)
+		}
+	}
+	sort.Strings(entityIDs)
+
+	return t.entityInfos(entityIDs)
+}
+
+// Device returns a device of the device registry
+func (t *Topology) Device(deviceID string) (Device, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	device, ok := t.devices[deviceID]
+	return device, ok
+}
+
+// Area returns an area of the area registry
+func (t *Topology) Area(areaID string) (Area, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	area, ok := t.areas[areaID]
+	return area, ok
+}
+
+// Floor returns a floor of the floor registry
+func (t *Topology) Floor(floorID string) (Floor, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	floor, ok := t.floors[floorID]
+	return floor, ok
+}
+
+// Floors returns all floors sorted by level, floors without a level last
+func (t *Topology) Floors() []Floor {
+	t.mu.RLock()
+	floors := make([]Floor, 0, len(t.floors))
+	for _, floor := range t.floors {
+		floors = append(floors, floor)
+	}
+	t.mu.RUnlock()
+
+	sort.Slice(floors, func(i, j int) bool {
+		a, b := floors[i].Level, floors[j].Level
+		switch {
+		case a != nil && b != nil && *a != *b:
+			return *a < *b
+		case a != nil && b == nil:
+			return true
+		case a == nil && b != nil:
+			return false
+		}
+		return floors[i].Name < floors[j].Name
+	})
+	return floors
+}
+
+// EntitiesOfDevice returns the entities that belong to a device
+func (t *Topology) EntitiesOfDevice(deviceID string) []EntityInfo {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.entityInfos(t.entitiesByDevice[deviceID])
+}
+
+// EntitiesInArea returns the entities in an area, either assigned directly or
+// through their device
+func (t *Topology) EntitiesInArea(areaID string) []EntityInfo {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.entityInfos(t.entitiesByArea[areaID])
+}
+
+// EntitiesOnFloor returns the entities in all areas of a floor
+func (t *Topology) EntitiesOnFloor(floorID string) []EntityInfo {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var infos []EntityInfo
+	for _, areaID := range t.areasByFloor[floorID] {
+		infos = append(infos, t.entityInfos(t.entitiesByArea[areaID])...)
+	}
+	return infos
+}
+
+// DevicesInArea returns the devices assigned to an area
+func (t *Topology) DevicesInArea(areaID string) []Device {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	devices := make([]Device, 0, len(t.devicesByArea[areaID]))
+	for _, deviceID := range t.devicesByArea[areaID] {
+		devices = append(devices, t.devices[deviceID])
+	}
+	return devices
+}
+
+// AreasOnFloor returns the areas of a floor
+func (t *Topology) AreasOnFloor(floorID string) []Area {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	areas := make([]Area, 0, len(t.areasByFloor[floorID]))
+	for _, areaID := range t.areasByFloor[floorID] {
+		areas = append(areas, t.areas[areaID])
+	}
+	return areas
+}
+
+// Watch keeps the topology up to date: states are mirrored with
+// SubscribeEntities and registries are reloaded whenever Home Assistant fires
+// a *_registry_updated event, as well as after a reconnect. Call Close to stop.
+// Watching again after Close is allowed; Watch does nothing while watching.
+func (t *Topology) Watch(ctx context.Context) error {
+	t.watchMu.Lock()
+	defer t.watchMu.Unlock()
+
+	if t.watching() {
+		return nil
+	}
+
+	mirror, err := t.client.SubscribeEntities(ctx)
+	if err != nil {
+		return err
+	}
+	if err := mirror.WaitReady(ctx); err != nil {
+		unsubscribeAll(mirror, nil)
+		return err
+	}
+
+	var subs []*EventSubscription
+	for _, eventType := range []string{EventEntityRegistryUpdated, EventDeviceRegistryUpdated, EventAreaRegistryUpdated, EventFloorRegistryUpdated} {
+		sub, err := t.client.SubscribeEvents(ctx, eventType)
+		if err != nil {
+			unsubscribeAll(mirror, subs)
+			return err
+		}
+		subs = append(subs, sub)
+		go t.watchRegistry(sub)
+	}
+
+	t.mu.Lock()
+	t.mirror = mirror
+	t.subs = subs
+	t.mu.Unlock()
+
+	// Registry events fired while disconnected are lost. Reconnect handlers
+	// cannot be removed, so the hook is registered once and checks whether
+	// the topology is being watched.
+	t.hookOnce.Do(func() {
+		t.client.OnReconnect(func() {
+			if !t.watching() {
+				return
+			}
+			if err := t.Refresh(context.Background()); err != nil {
+				log.Printf("Failed to refresh topology after reconnect: %v", err)
+			}
+		})
+	})
+
+	return nil
+}
+
+// watching reports whether Watch is active
+func (t *Topology) watching() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.mirror != nil
+}
+
+// unsubscribeAll ends the subscriptions of a failed Watch. The caller's context
+// may already be done, so a fresh one is used to reach the server.
+func unsubscribeAll(mirror *EntityMirror, subs []*EventSubscription) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := mirror.Unsubscribe(ctx); err != nil {
+		log.Printf("Failed to unsubscribe from entities: %v", err)
+	}
+	for _, sub := range subs {
+		if err := sub.Unsubscribe(ctx); err != nil {
+			log.Printf("Failed to unsubscribe from registry events: %v", err)
+		}
+	}
+}
+
+// watchRegistry reloads a registry for every update event until the subscription ends
+func (t *Topology) watchRegistry(sub *EventSubscription) {
+	for event := range sub.Events() {
+		// A burst of updates needs a single reload
+		for drained := false; !drained; {
+			select {
+			case _, ok := <-sub.Events():
+				if !ok {
+					return
+				}
+			default:
+				drained = true
+			}
+		}
+
+		if err := t.refreshRegistry(context.Background(), event.EventType); err != nil {
+			log.Printf("Failed to refresh topology after %s: %v", event.EventType, err)
+		}
+	}
+}
+
+// Close stops watching for changes
+func (t *Topology) Close(ctx context.Context) error {
+	t.watchMu.Lock()
+	defer t.watchMu.Unlock()
+
+	t.mu.Lock()
+	mirror, subs := t.mirror, t.subs
+	t.mirror, t.subs = nil, nil
+	t.mu.Unlock()
+
+	var firstErr error
+	if mirror != nil {
+		if err := mirror.Unsubscribe(ctx); err != nil {
+			firstErr = err
+		}
+	}
+	for _, sub := range subs {
+		if err := sub.Unsubscribe(ctx); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,232 @@
+package hago
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Trigger is a Home Assistant trigger definition evaluated by the server
+type Trigger interface {
+	// TriggerConfig returns the trigger configuration as sent to Home Assistant
+	TriggerConfig() map[string]interface{}
+}
+
+// RawTrigger is a trigger configuration passed through as is, for platforms
+// without a typed builder. It must contain the "platform" key.
+type RawTrigger map[string]interface{}
+
+// TriggerConfig implements Trigger
+func (t RawTrigger) TriggerConfig() map[string]interface{} {
+	return t
+}
+
+// StateTrigger fires when the state or an attribute of entities changes
+type StateTrigger struct {
+	EntityIDs []string
+	// Attribute watches an attribute instead of the state
+	Attribute string
+	// From and To restrict the transition; empty matches any value
+	From string
+	To   string
+	// For requires the new value to hold for this long
+	For time.Duration
+}
+
+// TriggerConfig implements Trigger
+func (t StateTrigger) TriggerConfig() map[string]interface{} {
+	config := map[string]interface{}{
+		"platform":  "state",
+		"entity_id": t.EntityIDs,
+	}
+	if t.Attribute != "" {
+		config["attribute"] = t.Attribute
+	}
+	if t.From != "" {
+		config["from"] = t.From
+	}
+	if t.To != "" {
+		config["to"] = t.To
+	}
+	if t.For > 0 {
+		config["for"] = durationConfig(t.For)
+	}
+	return config
+}
+
+// NumericStateTrigger fires when a numeric value crosses the given thresholds
+type NumericStateTrigger struct {
+	EntityIDs []string
+	// Attribute watches an attribute instead of the state
+	Attribute string
+	// Above and Below are the thresholds; at least one is required
+	Above *float64
+	Below *float64
+	// ValueTemplate transforms the value before it is compared
+	ValueTemplate string
+	// For requires the value to stay in range for this long
+	For time.Duration
+}
+
+// TriggerConfig implements Trigger
+func (t NumericStateTrigger) TriggerConfig() map[string]interface{} {
+	config := map[string]interface{}{
+		"platform":  "numeric_state",
+		"entity_id": t.EntityIDs,
+	}
+	if t.Attribute != "" {
+		config["attribute"] = t.Attribute
+	}
+	if t.Above != nil {
+		config["above"] = *t.Above
+	}
+	if t.Below != nil {
+		config["below"] = *t.Below
+	}
+	if t.ValueTemplate != "" {
+		config["value_template"] = t.ValueTemplate
+	}
+	if t.For > 0 {
+		config["for"] = durationConfig(t.For)
+	}
+	return config
+}
+
+// TimeTrigger fires at the given times of day ("HH:MM:SS") or at the times
+// stored in input_datetime or timestamp sensor entities
+type TimeTrigger struct {
+	At []string
+}
+
+// TriggerConfig implements Trigger
+func (t TimeTrigger) TriggerConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"platform": "time",
+		"at":       t.At,
+	}
+}
+
+// TemplateTrigger fires when a template starts rendering true
+type TemplateTrigger struct {
+	ValueTemplate string
+	// For requires the template to stay true for this long
+	For time.Duration
+}
+
+// TriggerConfig implements Trigger
+func (t TemplateTrigger) TriggerConfig() map[string]interface{} {
+	config := map[string]interface{}{
+		"platform":       "template",
+		"value_template": t.ValueTemplate,
+	}
+	if t.For > 0 {
+		config["for"] = durationConfig(t.For)
+	}
+	return config
+}
+
+// ZoneTrigger fires when entities enter or leave a zone
+type ZoneTrigger struct {
+	EntityIDs []string
+	// Zone is the zone entity, e.g. "zone.home"
+	Zone string
+	// Event is "enter" or "leave"
+	Event string
+}
+
+// TriggerConfig implements Trigger
+func (t ZoneTrigger) TriggerConfig() map[string]interface{} {
+	event := t.Event
+	if event == "" {
+		event = "enter"
+	}
+	return map[string]interface{}{
+		"platform":  "zone",
+		"entity_id": t.EntityIDs,
+		"zone":      t.Zone,
+		"event":     event,
+	}
+}
+
+// durationConfig converts a duration to the time period format of Home Assistant
+func durationConfig(d time.Duration) map[string]interface{} {
+	return map[string]interface{}{
+		"hours":        int(d / time.Hour),
+		"minutes":      int(d % time.Hour / time.Minute),
+		"seconds":      int(d % time.Minute / time.Second),
+		"milliseconds": int(d % time.Second / time.Millisecond),
+	}
+}
+
+// TriggerEvent is delivered every time a subscribed trigger fires
+type TriggerEvent struct {
+	// Variables holds the trigger variables; details of the firing trigger are under "trigger"
+	Variables map[string]interface{} `json:"variables"`
+	Context   *Context               `json:"context"`
+}
+
+// Trigger returns the details of the trigger that fired, such as its platform,
+// entity_id and from_state/to_state
+func (e TriggerEvent) Trigger() map[string]interface{} {
+	trigger, _ := e.Variables["trigger"].(map[string]interface{})
+	return trigger
+}
+
+// TriggerSubscription is a subscription to server-side triggers
+type TriggerSubscription struct {
+	*Subscription
+	events chan TriggerEvent
+}
+
+// Events returns the channel trigger events are delivered on.
+// The channel is closed when the subscription ends. It must be drained,
+// see EventSubscription.Events.
+func (s *TriggerSubscription) Events() <-chan TriggerEvent {
+	return s.events
+}
+
+// SubscribeTrigger lets Home Assistant evaluate the given triggers and delivers
+// an event each time one of them fires. variables are made available to the
+// trigger templates and may be nil.
+func (c *WSClient) SubscribeTrigger(ctx context.Context, variables map[string]interface{}, triggers ...Trigger) (*TriggerSubscription, error) {
+	if len(triggers) == 0 {
+		return nil, fmt.Errorf("%w: at least one trigger is required", ErrInvalidArgument)
+	}
+
+	configs := make([]map[string]interface{}, 0, len(triggers))
+	for _, trigger := range triggers {
+		configs = append(configs, trigger.TriggerConfig())
+	}
+
+	message := map[string]interface{}{
+		"type":    "subscribe_trigger",
+		"trigger": configs,
+	}
+	if variables != nil {
+		message["variables"] = variables
+	}
+
+	sub := &TriggerSubscription{
+		events: make(chan TriggerEvent, c.dispatchPolicy.QueueSize),
+	}
+	sub.Subscription = c.newSubscription(message, func(msg *wsMessage) {
+		var event TriggerEvent
+		if err := json.Unmarshal(msg.Event, &event); err != nil {
+			log.Printf("Error decoding trigger event: %v", err)
+			return
+		}
+		deliver(sub.Subscription, sub.events, event)
+	})
+	sub.onClose = func() {
+		close(sub.events)
+	}
+
+	// An invalid trigger configuration is rejected in the command result
+	if err := c.subscribe(ctx, sub.Subscription); err != nil {
+		return nil, fmt.Errorf("failed to subscribe to trigger: %w", err)
+	}
+
+	return sub, nil
+}
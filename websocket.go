@@ -1,9 +1,13 @@
 package hago
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -13,100 +17,326 @@ import (
 
 // WSClient represents a WebSocket client for Home Assistant
 type WSClient struct {
-	URL         string
-	AccessToken string
-	conn        *websocket.Conn
-	mu          sync.Mutex
-	connected   bool
-	msgID       int64
-	handlers    map[string][]func(msg map[string]interface{})
-	done        chan struct{}
+	URL               string
+	AccessToken       string
+	conn              *websocket.Conn
+	dialer            *websocket.Dialer
+	header            http.Header
+	mu                sync.Mutex
+	connectMu         sync.Mutex // serializes Connect without blocking Close
+	connected         bool
+	msgID             int64
+	handlers          map[string][]*eventHandler
+	subscriptions     map[int64]*Subscription
+	onReconnect       []func()
+	pending           map[int64]chan callResult
+	callTimeout       time.Duration
+	reconnectPolicy   ReconnectPolicy
+	heartbeatInterval time.Duration
+	heartbeatTimeout  time.Duration
+	features          []string
+	dispatchPolicy    DispatchPolicy
+	queues            []*dispatchQueue
+	queued            atomic.Uint64
+	dropped           atomic.Uint64
+	reconnecting      bool
+	closed            bool
+	done              chan struct{}
+
+	// Connection state, guarded by its own lock so that it can be read
+	// while a connect or reconnect holds mu
+	stateMu       sync.Mutex
+	state         ConnectionState
+	stateErr      error
+	stateChanged  chan struct{}
+	stateHandlers []func(ConnectionEvent)
+	stateQueue    []ConnectionEvent
+	stateDraining bool
+	haVersion     string
+}
+
+// wsMessage is the envelope of every message received from Home Assistant.
+// Payloads are kept raw so they are only decoded by whoever consumes them.
+type wsMessage struct {
+	ID      int64           `json:"id"`
+	Type    string          `json:"type"`
+	Success bool            `json:"success"`
+	Result  json.RawMessage `json:"result"`
+	Error   *WSError        `json:"error"`
+	Event   json.RawMessage `json:"event"`
+}
+
+// callResult carries the answer to a command sent with Call
+type callResult struct {
+	msg *wsMessage
+	err error
 }
 
 // NewWSClient creates a new WebSocket client
-func NewWSClient(url, accessToken string) *WSClient {
+func NewWSClient(url, accessToken string, opts ...Option) *WSClient {
+	o := newOptions(opts)
+
+	callTimeout := o.timeout
+	if callTimeout == 0 {
+		callTimeout = defaultTimeout
+	}
+
+	reconnectPolicy := o.reconnectPolicy
+	if reconnectPolicy == nil {
+		reconnectPolicy = NoReconnect()
+	}
+
+	dispatchPolicy := o.dispatchPolicy
+	defaults := DefaultDispatchPolicy()
+	if dispatchPolicy.QueueSize <= 0 {
+		dispatchPolicy.QueueSize = defaults.QueueSize
+	}
+	if dispatchPolicy.Workers <= 0 {
+		dispatchPolicy.Workers = defaults.Workers
+	}
+
 	return &WSClient{
-		URL:         url,
-		AccessToken: accessToken,
-		msgID:       0,
-		handlers:    make(map[string][]func(msg map[string]interface{})),
-		done:        make(chan struct{}),
+		URL:               url,
+		AccessToken:       accessToken,
+		dialer:            o.buildDialer(),
+		header:            o.header,
+		msgID:             0,
+		handlers:          make(map[string][]*eventHandler),
+		subscriptions:     make(map[int64]*Subscription),
+		pending:           make(map[int64]chan callResult),
+		callTimeout:       callTimeout,
+		reconnectPolicy:   reconnectPolicy,
+		heartbeatInterval: o.heartbeatInterval,
+		heartbeatTimeout:  o.heartbeatTimeout,
+		features:          o.features,
+		dispatchPolicy:    dispatchPolicy,
+		done:              make(chan struct{}),
+		stateChanged:      make(chan struct{}),
 	}
 }
 
-// Connect establishes a WebSocket connection to Home Assistant
-func (c *WSClient) Connect() error {
+// Connect establishes a WebSocket connection to Home Assistant.
+// ctx bounds both the dial and the authentication handshake; without a
+// deadline the handshake is limited to the client timeout. Close aborts a
+// Connect in progress. Connect can be called again after the client gave up
+// reconnecting; active subscriptions are then replayed. While a reconnect is
+// in progress Connect waits for it and returns the reason when it gives up.
+func (c *WSClient) Connect(ctx context.Context) error {
+	c.connectMu.Lock()
+	defer c.connectMu.Unlock()
+
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	closed, connected, reconnecting := c.closed, c.connected, c.reconnecting
+	c.mu.Unlock()
 
-	if c.connected {
+	switch {
+	case closed:
+		return ErrClientClosed
+	case connected:
 		return nil
+	case reconnecting:
+		// The reader is already restoring the connection
+		return c.waitReconnect(ctx)
 	}
 
-	conn, _, err := websocket.DefaultDialer.Dial(c.URL, nil)
+	// Abort the dial as soon as the client is closed
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-c.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	c.setState(ConnectionEvent{State: ConnConnecting})
+	conn, err := c.dial(ctx, 0)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		if conn != nil {
+			conn.Close()
+		}
+		return ErrClientClosed
+	}
 	if err != nil {
-		return fmt.Errorf("failed to connect to WebSocket: %v", err)
+		c.setState(ConnectionEvent{State: ConnDisconnected, Err: err})
+		return err
 	}
 
 	c.conn = conn
 	c.connected = true
+	c.setState(ConnectionEvent{State: ConnConnected})
 
 	// Start the message reader
-	go c.readMessages()
+	go c.readMessages(conn)
+	if c.heartbeatInterval > 0 {
+		go c.heartbeat(conn)
+	}
+
+	// Subscriptions survive a give-up, replay them on the new connection
+	if len(c.subscriptions) > 0 {
+		go c.resubscribe()
+	}
+
+	return nil
+}
+
+// dial opens and authenticates a new connection
+func (c *WSClient) dial(ctx context.Context, attempt int) (*websocket.Conn, error) {
+	conn, _, err := c.dialer.DialContext(ctx, c.URL, c.header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to WebSocket: %w", err)
+	}
+
+	// Reads and writes do not watch ctx: bound the handshake by its deadline,
+	// or by the call timeout without one, and close the connection when ctx
+	// is done first
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(c.callTimeout)
+	}
+	conn.SetWriteDeadline(deadline)
+	conn.SetReadDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
 
 	// Authenticate with Home Assistant
-	err = c.authenticate()
+	c.setState(ConnectionEvent{State: ConnAuthenticating, Attempt: attempt})
+	err = c.authenticate(conn)
+	if !stop() {
+		err = ctx.Err()
+	}
 	if err != nil {
-		c.conn.Close()
-		c.connected = false
-		return fmt.Errorf("authentication failed: %v", err)
+		conn.Close()
+		return nil, fmt.Errorf("authentication failed: %w", err)
 	}
 
-	return nil
+	conn.SetWriteDeadline(time.Time{})
+	conn.SetReadDeadline(time.Time{})
+	return conn, nil
+}
+
+// handshakeMessage is a message exchanged during the auth phase
+type handshakeMessage struct {
+	Type      string `json:"type"`
+	HAVersion string `json:"ha_version"`
+	Message   string `json:"message"`
 }
 
-// authenticate sends an authentication message to Home Assistant
-func (c *WSClient) authenticate() error {
+// authenticate runs the handshake on a new connection before the reader
+// starts: Home Assistant announces itself with auth_required, the client
+// answers with its access token and the server accepts or rejects it.
+// Supported features are negotiated afterwards.
+func (c *WSClient) authenticate(conn *websocket.Conn) error {
+	var hello handshakeMessage
+	if err := conn.ReadJSON(&hello); err != nil {
+		return err
+	}
+	if hello.Type != "auth_required" {
+		return fmt.Errorf("unexpected handshake message %q", hello.Type)
+	}
+
 	authMsg := map[string]interface{}{
 		"type":         "auth",
 		"access_token": c.AccessToken,
 	}
-
-	err := c.conn.WriteJSON(authMsg)
-	if err != nil {
+	if err := conn.WriteJSON(authMsg); err != nil {
 		return err
 	}
 
 	// Wait for auth response
-	_, message, err := c.conn.ReadMessage()
-	if err != nil {
+	var response handshakeMessage
+	if err := conn.ReadJSON(&response); err != nil {
 		return err
 	}
 
-	var response map[string]interface{}
-	if err := json.Unmarshal(message, &response); err != nil {
+	switch response.Type {
+	case "auth_ok":
+	case "auth_invalid":
+		return fmt.Errorf("%w: %s", ErrAuthFailed, response.Message)
+	default:
+		return fmt.Errorf("unexpected handshake message %q", response.Type)
+	}
+
+	version := response.HAVersion
+	if version == "" {
+		version = hello.HAVersion
+	}
+	c.stateMu.Lock()
+	c.haVersion = version
+	c.stateMu.Unlock()
+
+	return c.negotiateFeatures(conn)
+}
+
+// negotiateFeatures enables the supported features requested through
+// WithSupportedFeatures. Servers that do not know the command keep the
+// defaults, which the client handles as well.
+func (c *WSClient) negotiateFeatures(conn *websocket.Conn) error {
+	if len(c.features) == 0 {
+		return nil
+	}
+
+	features := make(map[string]int, len(c.features))
+	for _, feature := range c.features {
+		features[feature] = 1
+	}
+
+	id := c.nextID()
+	err := conn.WriteJSON(map[string]interface{}{
+		"id":       id,
+		"type":     "supported_features",
+		"features": features,
+	})
+	if err != nil {
 		return err
 	}
 
-	if response["type"] != "auth_ok" {
-		return fmt.Errorf("authentication failed: %v", response["message"])
+	// Nothing is subscribed yet, so the next answer is ours
+	var msg wsMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		return err
+	}
+	if msg.Type != "result" || msg.ID != id {
+		return fmt.Errorf("unexpected message %q while negotiating features", msg.Type)
+	}
+	if !msg.Success {
+		log.Printf("Home Assistant did not accept supported features %v: %v", c.features, msg.Error)
 	}
 
 	return nil
 }
 
-// Close closes the WebSocket connection
+// Close closes the WebSocket connection. Commands in flight fail with
+// ErrClientClosed, and so does every later command. A closed client cannot be
+// reconnected.
 func (c *WSClient) Close() error {
 	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	if !c.connected {
+	if c.closed {
+		c.mu.Unlock()
 		return nil
 	}
 
+	c.closed = true
 	close(c.done)
-	err := c.conn.Close()
-	c.connected = false
+	var err error
+	if c.connected {
+		err = c.conn.Close()
+		c.connected = false
+	}
+	c.mu.Unlock()
+
+	c.setState(ConnectionEvent{State: ConnClosed})
+
+	// Commands in flight will never be answered
+	c.failPending(ErrClientClosed)
+
+	// Subscriptions cannot outlive the client
+	c.closeSubscriptions()
+
 	return err
 }
 
@@ -115,87 +345,216 @@ func (c *WSClient) nextID() int64 {
 	return atomic.AddInt64(&c.msgID, 1)
 }
 
-// readMessages reads messages from the WebSocket connection
-func (c *WSClient) readMessages() {
+// readMessages reads messages from the WebSocket connection until the client
+// is closed or reconnecting is given up
+func (c *WSClient) readMessages(conn *websocket.Conn) {
 	for {
-		select {
-		case <-c.done:
-			return
-		default:
-			_, message, err := c.conn.ReadMessage()
-			if err != nil {
-				log.Printf("Error reading WebSocket message: %v", err)
-				c.reconnect()
-				continue
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			select {
+			case <-c.done:
+				return
+			default:
 			}
 
-			var msg map[string]interface{}
-			if err := json.Unmarshal(message, &msg); err != nil {
-				log.Printf("Error unmarshaling message: %v", err)
-				continue
-			}
+			log.Printf("Error reading WebSocket message: %v", err)
+			// Commands in flight will never be answered on the old connection
+			c.failPending(ErrConnectionLost)
 
-			// Handle message based on its type
-			if msgType, ok := msg["type"].(string); ok {
-				c.handleMessage(msgType, msg)
+			conn = c.reconnect(conn, err)
+			if conn == nil {
+				return
 			}
+
+			// Replay subscriptions without blocking the reader,
+			// which has to receive their results
+			go c.resubscribe()
+			continue
 		}
+
+		c.handleFrame(message)
+	}
+}
+
+// handleFrame dispatches every message of a frame. With coalesce_messages
+// enabled Home Assistant batches messages into a single JSON array.
+func (c *WSClient) handleFrame(frame []byte) {
+	frame = bytes.TrimLeft(frame, " \t\r\n")
+	if len(frame) == 0 || frame[0] != '[' {
+		c.decodeMessage(frame)
+		return
+	}
+
+	var batch []json.RawMessage
+	if err := json.Unmarshal(frame, &batch); err != nil {
+		log.Printf("Error unmarshaling message batch: %v", err)
+		return
+	}
+	for _, raw := range batch {
+		c.decodeMessage(raw)
+	}
+}
+
+// decodeMessage decodes a single message and dispatches it
+func (c *WSClient) decodeMessage(raw []byte) {
+	var msg wsMessage
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		log.Printf("Error unmarshaling message: %v", err)
+		return
 	}
+
+	c.handleMessage(&msg, raw)
 }
 
-// handleMessage dispatches the message to registered handlers
-func (c *WSClient) handleMessage(msgType string, msg map[string]interface{}) {
+// handleMessage dispatches the message to the command waiting for it, the
+// subscription it belongs to and registered handlers. Subscription events are
+// handled synchronously, so they are seen in the order they arrived.
+func (c *WSClient) handleMessage(msg *wsMessage, raw []byte) {
 	c.mu.Lock()
-	handlers, ok := c.handlers[msgType]
+	handlers := c.handlers[msg.Type]
+	var sub *Subscription
+	if msg.Type == "event" {
+		sub = c.subscriptions[msg.ID]
+	}
+	var resultCh chan callResult
+	if msg.Type == "result" || msg.Type == "pong" {
+		resultCh = c.pending[msg.ID]
+		delete(c.pending, msg.ID)
+	}
 	c.mu.Unlock()
 
-	if ok {
-		for _, handler := range handlers {
-			go handler(msg)
+	if resultCh != nil {
+		resultCh <- callResult{msg: msg}
+	}
+
+	if sub != nil {
+		sub.handle(msg)
+	}
+
+	if len(handlers) > 0 {
+		// Legacy handlers receive the generic decoded form
+		var decoded map[string]interface{}
+		if err := json.Unmarshal(raw, &decoded); err != nil {
+			log.Printf("Error unmarshaling message: %v", err)
+			return
 		}
+		c.dispatch(handlers, decoded)
 	}
 }
 
-// reconnect attempts to reconnect to the WebSocket server
-func (c *WSClient) reconnect() {
+// failPending aborts every command waiting for a result with err
+func (c *WSClient) failPending(err error) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	pending := c.pending
+	c.pending = make(map[int64]chan callResult)
+	c.mu.Unlock()
 
-	if !c.connected {
-		return
+	for _, resultCh := range pending {
+		resultCh <- callResult{err: err}
 	}
+}
 
+// reconnect replaces a lost connection following the reconnect policy.
+// It returns the new connection, or nil when the client was closed or the
+// policy gave up, in which case the client is left disconnected.
+func (c *WSClient) reconnect(lost *websocket.Conn, cause error) *websocket.Conn {
+	c.mu.Lock()
+	if c.closed || c.conn != lost {
+		c.mu.Unlock()
+		return nil
+	}
 	c.conn.Close()
 	c.connected = false
+	c.reconnecting = true
+	c.mu.Unlock()
 
-	maxRetries := 5
-	for i := 0; i < maxRetries; i++ {
-		log.Printf("Attempting to reconnect to WebSocket (attempt %d/%d)", i+1, maxRetries)
-		conn, _, err := websocket.DefaultDialer.Dial(c.URL, nil)
-		if err == nil {
-			c.conn = conn
-			c.connected = true
-			if err := c.authenticate(); err != nil {
-				conn.Close()
-				c.connected = false
-				log.Printf("Authentication failed during reconnect: %v", err)
-			} else {
-				log.Printf("Successfully reconnected to WebSocket")
-				return
+	defer func() {
+		c.mu.Lock()
+		c.reconnecting = false
+		c.mu.Unlock()
+	}()
+
+	// Abort pending dials as soon as the client is closed
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-c.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	lostAt := time.Now()
+	lastErr := cause
+	for attempt := 1; ; attempt++ {
+		delay, ok := c.reconnectPolicy.NextDelay(attempt, time.Since(lostAt))
+		if !ok {
+			err := fmt.Errorf("gave up reconnecting after %d attempts: %w", attempt-1, lastErr)
+			log.Printf("Failed to reconnect to WebSocket: %v", err)
+			c.failPending(err)
+			c.setState(ConnectionEvent{State: ConnDisconnected, Err: err})
+			return nil
+		}
+
+		c.setState(ConnectionEvent{State: ConnReconnecting, Err: lastErr, Attempt: attempt})
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil
+		}
+
+		log.Printf("Attempting to reconnect to WebSocket (attempt %d)", attempt)
+		attemptCtx, attemptCancel := context.WithTimeout(ctx, c.callTimeout)
+		conn, err := c.dial(attemptCtx, attempt)
+		attemptCancel()
+		if err != nil {
+			// A rejected token will not become valid by retrying, and repeated
+			// failed logins get the client banned by Home Assistant
+			if errors.Is(err, ErrAuthFailed) {
+				log.Printf("Giving up reconnecting to WebSocket: %v", err)
+				c.failPending(err)
+				c.setState(ConnectionEvent{State: ConnDisconnected, Err: err, Attempt: attempt})
+				return nil
 			}
+
+			lastErr = err
+			log.Printf("Reconnect attempt %d failed: %v", attempt, err)
+			continue
+		}
+
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			conn.Close()
+			return nil
 		}
-		time.Sleep(time.Second * 3 * time.Duration(i+1))
+		c.conn = conn
+		c.connected = true
+		c.mu.Unlock()
+
+		log.Printf("Successfully reconnected to WebSocket")
+		c.setState(ConnectionEvent{State: ConnConnected})
+		if c.heartbeatInterval > 0 {
+			go c.heartbeat(conn)
+		}
+		return conn
 	}
-	log.Printf("Failed to reconnect to WebSocket after %d attempts", maxRetries)
 }
 
-// Send sends a message to the WebSocket server
-func (c *WSClient) Send(message map[string]interface{}) error {
+// Send sends a message to the WebSocket server.
+// If ctx carries a deadline it is applied to the write.
+func (c *WSClient) Send(ctx context.Context, message map[string]interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	if c.closed {
+		return ErrClientClosed
+	}
 	if !c.connected {
-		return fmt.Errorf("not connected to WebSocket")
+		return ErrNotConnected
 	}
 
 	// Add message ID if not present
@@ -203,41 +562,144 @@ func (c *WSClient) Send(message map[string]interface{}) error {
 		message["id"] = c.nextID()
 	}
 
+	if deadline, ok := ctx.Deadline(); ok {
+		c.conn.SetWriteDeadline(deadline)
+		defer c.conn.SetWriteDeadline(time.Time{})
+	}
+
 	return c.conn.WriteJSON(message)
 }
 
-// SubscribeEvents subscribes to Home Assistant events
-func (c *WSClient) SubscribeEvents(eventType string) error {
-	message := map[string]interface{}{
-		"type": "subscribe_events",
-		"id":   c.nextID(),
+// UnsubscribeEvents ends the subscription with the given ID.
+// Prefer Subscription.Unsubscribe when the subscription object is at hand.
+func (c *WSClient) UnsubscribeEvents(ctx context.Context, subscription int64) error {
+	c.mu.Lock()
+	sub, ok := c.subscriptions[subscription]
+	c.mu.Unlock()
+
+	if ok {
+		return sub.Unsubscribe(ctx)
 	}
 
-	if eventType != "" {
-		message["event_type"] = eventType
+	_, err := c.Call(ctx, map[string]interface{}{
+		"type":         "unsubscribe_events",
+		"subscription": subscription,
+	})
+	return err
+}
+
+// AddEventHandler registers a handler function for messages of the given type.
+// Handlers run off the reader goroutine as configured by the dispatch policy,
+// see WithDispatchPolicy.
+func (c *WSClient) AddEventHandler(eventType string, handler func(msg map[string]interface{})) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	h := &eventHandler{fn: handler}
+	if c.dispatchPolicy.Mode == DispatchPerHandler {
+		h.queue = c.newDispatchQueue(c.done, nil)
 	}
+	c.handlers[eventType] = append(c.handlers[eventType], h)
+}
 
-	return c.Send(message)
+// Call sends a command and waits for Home Assistant to answer it. The message
+// is assigned a fresh ID. On success the raw "result" payload is returned;
+// if Home Assistant rejects the command the error wraps a *WSError.
+// When ctx has no deadline the client's timeout (see WithTimeout) applies.
+func (c *WSClient) Call(ctx context.Context, message map[string]interface{}) (json.RawMessage, error) {
+	return c.call(ctx, c.nextID(), message)
 }
 
-// UnsubscribeEvents unsubscribes from Home Assistant events
-func (c *WSClient) UnsubscribeEvents(subscription int64) error {
-	message := map[string]interface{}{
-		"type":         "unsubscribe_events",
-		"id":           c.nextID(),
-		"subscription": subscription,
+// call sends a command with the given ID and waits for its answer
+func (c *WSClient) call(ctx context.Context, id int64, message map[string]interface{}) (json.RawMessage, error) {
+	if _, ok := ctx.Deadline(); !ok && c.callTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.callTimeout)
+		defer cancel()
+	}
+
+	message["id"] = id
+
+	// Register before sending so a fast answer cannot be missed
+	resultCh := make(chan callResult, 1)
+	c.mu.Lock()
+	c.pending[id] = resultCh
+	c.mu.Unlock()
+
+	if err := c.Send(ctx, message); err != nil {
+		c.removePending(id)
+		return nil, err
 	}
 
-	return c.Send(message)
+	select {
+	case <-ctx.Done():
+		c.removePending(id)
+		return nil, fmt.Errorf("command %v: %w", message["type"], ctx.Err())
+	case res := <-resultCh:
+		if res.err != nil {
+			return nil, fmt.Errorf("command %v: %w", message["type"], res.err)
+		}
+		if res.msg.Type == "result" && !res.msg.Success {
+			if res.msg.Error == nil {
+				res.msg.Error = &WSError{Code: "unknown_error"}
+			}
+			return nil, fmt.Errorf("command %v failed: %w", message["type"], res.msg.Error)
+		}
+		return res.msg.Result, nil
+	}
 }
 
-// AddEventHandler registers a handler function for event messages
-func (c *WSClient) AddEventHandler(eventType string, handler func(msg map[string]interface{})) {
+// removePending forgets a command that is no longer awaited
+func (c *WSClient) removePending(id int64) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if _, ok := c.handlers[eventType]; !ok {
-		c.handlers[eventType] = []func(msg map[string]interface{}){}
+	delete(c.pending, id)
+}
+
+// CallService calls a Home Assistant service on target over the WebSocket connection
+func (c *WSClient) CallService(ctx context.Context, domain, service string, target Target, data map[string]interface{}) (*ServiceCallResult, error) {
+	return c.callService(ctx, domain, service, target, data, false)
+}
+
+// CallServiceWithResponse calls a service that returns response data, such as
+// weather.get_forecasts or calendar.get_events
+func (c *WSClient) CallServiceWithResponse(ctx context.Context, domain, service string, target Target, data map[string]interface{}) (*ServiceCallResult, error) {
+	return c.callService(ctx, domain, service, target, data, true)
+}
+
+// callService sends a call_service command and decodes its result
+func (c *WSClient) callService(ctx context.Context, domain, service string, target Target, data map[string]interface{}, returnResponse bool) (*ServiceCallResult, error) {
+	message := map[string]interface{}{
+		"type":    "call_service",
+		"domain":  domain,
+		"service": service,
+	}
+	if data != nil {
+		message["service_data"] = data
+	}
+	if !target.IsEmpty() {
+		message["target"] = target.fields()
 	}
-	c.handlers[eventType] = append(c.handlers[eventType], handler)
+	if returnResponse {
+		message["return_response"] = true
+	}
+
+	result, err := c.Call(ctx, message)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call service %s.%s: %w", domain, service, err)
+	}
+
+	var payload struct {
+		Context  *Context               `json:"context"`
+		Response map[string]interface{} `json:"response"`
+	}
+	if err := json.Unmarshal(result, &payload); err != nil {
+		return nil, err
+	}
+
+	return &ServiceCallResult{
+		Response: payload.Response,
+		Context:  payload.Context,
+	}, nil
 }
@@ -0,0 +1,216 @@
+package hago
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// serveHA starts a fake Home Assistant WebSocket endpoint and returns its URL.
+// handle is called with every accepted connection and its index.
+func serveHA(t *testing.T, handle func(conn *websocket.Conn, n int)) string {
+	t.Helper()
+
+	var upgrader websocket.Upgrader
+	var count atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		handle(conn, int(count.Add(1))-1)
+	}))
+	t.Cleanup(srv.Close)
+
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+// serveAuth runs the server side of the auth phase
+func serveAuth(conn *websocket.Conn, accept bool) error {
+	if err := conn.WriteJSON(map[string]string{"type": "auth_required", "ha_version": "2024.1.0"}); err != nil {
+		return err
+	}
+
+	var auth map[string]interface{}
+	if err := conn.ReadJSON(&auth); err != nil {
+		return err
+	}
+
+	if !accept {
+		return conn.WriteJSON(map[string]string{"type": "auth_invalid", "message": "Invalid access token"})
+	}
+	return conn.WriteJSON(map[string]string{"type": "auth_ok", "ha_version": "2024.1.0"})
+}
+
+func TestHandleFrame(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame string
+		want  []int64
+	}{
+		{
+			name:  "single message",
+			frame: `{"id":1,"type":"result","success":true,"result":null}`,
+			want:  []int64{1},
+		},
+		{
+			name:  "leading whitespace",
+			frame: "\n  " + `{"id":2,"type":"result","success":true}`,
+			want:  []int64{2},
+		},
+		{
+			name:  "batch",
+			frame: `[{"id":1,"type":"result","success":true},{"id":3,"type":"pong"}]`,
+			want:  []int64{1, 3},
+		},
+		{
+			name:  "batch with leading whitespace",
+			frame: "\r\n" + `[{"id":2,"type":"result","success":true}]`,
+			want:  []int64{2},
+		},
+		{
+			name:  "empty batch",
+			frame: `[]`,
+		},
+		{
+			name:  "malformed batch",
+			frame: `[{"id":1,"type":"result"`,
+		},
+		{
+			name:  "malformed message in batch",
+			frame: `[{"id":1,"type":"result","success":true},"not a message",{"id":2,"type":"result","success":true}]`,
+			want:  []int64{1, 2},
+		},
+		{
+			name:  "unknown ID",
+			frame: `{"id":42,"type":"result","success":true}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewWSClient("ws://localhost:8123/api/websocket", "token")
+			for id := int64(1); id <= 3; id++ {
+				c.pending[id] = make(chan callResult, 1)
+			}
+			pending := make(map[int64]chan callResult, len(c.pending))
+			for id, ch := range c.pending {
+				pending[id] = ch
+			}
+
+			c.handleFrame([]byte(tt.frame))
+
+			var got []int64
+			for id := int64(1); id <= 3; id++ {
+				select {
+				case result := <-pending[id]:
+					if result.msg == nil || result.msg.ID != id {
+						t.Errorf("pending call %d got %+v", id, result.msg)
+					}
+					got = append(got, id)
+				default:
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("handleFrame(%q) answered calls %v, want %v", tt.frame, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConnectWhileReconnectGivesUp(t *testing.T) {
+	// The first connection drops right away and the token is rejected afterwards
+	url := serveHA(t, func(conn *websocket.Conn, n int) {
+		serveAuth(conn, n == 0)
+	})
+
+	policy := ReconnectPolicyFunc(func(attempt int, elapsed time.Duration) (time.Duration, bool) {
+		return 200 * time.Millisecond, true
+	})
+	c := NewWSClient(url, "token", WithReconnectPolicy(policy))
+	t.Cleanup(func() { c.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := c.Connect(ctx); err != nil {
+		t.Fatalf("Connect() = %v", err)
+	}
+	for c.ConnectionState() != ConnReconnecting {
+		if ctx.Err() != nil {
+			t.Fatal("client did not start reconnecting")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if err := c.Connect(ctx); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("Connect() while reconnecting = %v, want %v", err, ErrAuthFailed)
+	}
+}
+
+func TestConnectAbortsSilentHandshake(t *testing.T) {
+	// The server accepts the connection but never sends auth_required
+	url := serveHA(t, func(conn *websocket.Conn, n int) {
+		conn.ReadMessage()
+	})
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		connect func(c *WSClient) error
+		want    error
+	}{
+		{
+			name: "context cancelled",
+			connect: func(c *WSClient) error {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(100*time.Millisecond, cancel)
+				return c.Connect(ctx)
+			},
+			want: context.Canceled,
+		},
+		{
+			name: "client closed",
+			connect: func(c *WSClient) error {
+				time.AfterFunc(100*time.Millisecond, func() { c.Close() })
+				return c.Connect(context.Background())
+			},
+			want: ErrClientClosed,
+		},
+		{
+			name: "no deadline",
+			opts: []Option{WithTimeout(100 * time.Millisecond)},
+			connect: func(c *WSClient) error {
+				return c.Connect(context.Background())
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewWSClient(url, "token", tt.opts...)
+			t.Cleanup(func() { c.Close() })
+
+			errCh := make(chan error, 1)
+			go func() { errCh <- tt.connect(c) }()
+
+			select {
+			case err := <-errCh:
+				if err == nil || tt.want != nil && !errors.Is(err, tt.want) {
+					t.Errorf("Connect() = %v, want %v", err, tt.want)
+				}
+			case <-time.After(3 * time.Second):
+				t.Fatal("Connect() did not return")
+			}
+		})
+	}
+}